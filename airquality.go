@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// airQualityDefaultURL è l'endpoint dell'API air-quality di Open-Meteo
+const airQualityDefaultURL = "https://air-quality-api.open-meteo.com/v1/air-quality"
+
+// airQualityVariables sono le variabili richieste nel blocco current
+const airQualityVariables = "european_aqi,pm2_5,pm10,ozone,nitrogen_dioxide," +
+	"alder_pollen,birch_pollen,grass_pollen,mugwort_pollen,olive_pollen,ragweed_pollen"
+
+// AirQuality contiene qualità dell'aria e pollini per una posizione
+type AirQuality struct {
+	Time        time.Time `json:"time"`
+	EuropeanAQI float64   `json:"european_aqi"`
+	Level       string    `json:"level"`
+	PM25        float64   `json:"pm2_5"`
+	PM10        float64   `json:"pm10"`
+	Ozone       float64   `json:"ozone"`
+	NO2         float64   `json:"nitrogen_dioxide"`
+	Pollen      []Pollen  `json:"pollen"`
+}
+
+// Pollen rappresenta la concentrazione di un tipo di polline (granuli/m³)
+type Pollen struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+}
+
+// MaxPollen restituisce il polline con la concentrazione più alta
+func (a *AirQuality) MaxPollen() Pollen {
+	var top Pollen
+	for _, p := range a.Pollen {
+		if p.Value > top.Value {
+			top = p
+		}
+	}
+	return top
+}
+
+// exceeds indica se AQI o pollini superano le soglie di allerta
+func (a *AirQuality) exceeds(aqiLevel, pollenLevel float64) bool {
+	if aqiLevel > 0 && a.EuropeanAQI >= aqiLevel {
+		return true
+	}
+	return pollenLevel > 0 && a.MaxPollen().Value >= pollenLevel
+}
+
+// getAirQualityLevel restituisce la descrizione della fascia European AQI
+func getAirQualityLevel(aqi float64) string {
+	switch {
+	case aqi < 20:
+		return "🟢 Buona"
+	case aqi < 40:
+		return "🟡 Discreta"
+	case aqi < 60:
+		return "🟠 Moderata"
+	case aqi < 80:
+		return "🔴 Scadente"
+	case aqi < 100:
+		return "🟣 Molto scadente"
+	}
+	return "🟤 Estremamente scadente"
+}
+
+// getAirQuality recupera la qualità dell'aria, usando la cache
+func getAirQuality(lat, lon float64) (*AirQuality, error) {
+	return airQualityCache.get(context.Background(), locationKey(lat, lon), func(ctx context.Context) (*AirQuality, error) {
+		return fetchAirQuality(ctx, lat, lon)
+	})
+}
+
+// fetchAirQuality interroga l'API air-quality di Open-Meteo
+func fetchAirQuality(ctx context.Context, lat, lon float64) (*AirQuality, error) {
+	baseURL := airQualityURL
+	if baseURL == "" {
+		baseURL = airQualityDefaultURL
+	}
+
+	params := url.Values{}
+	params.Set("latitude", fmt.Sprintf("%.4f", lat))
+	params.Set("longitude", fmt.Sprintf("%.4f", lon))
+	params.Set("current", airQualityVariables)
+	params.Set("timezone", autoTimezone)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("air-quality API status %d", resp.StatusCode)
+	}
+
+	var data struct {
+		Timezone string `json:"timezone"`
+		Current  struct {
+			Time          string  `json:"time"`
+			EuropeanAQI   float64 `json:"european_aqi"`
+			PM25          float64 `json:"pm2_5"`
+			PM10          float64 `json:"pm10"`
+			Ozone         float64 `json:"ozone"`
+			NO2           float64 `json:"nitrogen_dioxide"`
+			AlderPollen   float64 `json:"alder_pollen"`
+			BirchPollen   float64 `json:"birch_pollen"`
+			GrassPollen   float64 `json:"grass_pollen"`
+			MugwortPollen float64 `json:"mugwort_pollen"`
+			OlivePollen   float64 `json:"olive_pollen"`
+			RagweedPollen float64 `json:"ragweed_pollen"`
+		} `json:"current"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	c := data.Current
+	observed, _ := time.ParseInLocation("2006-01-02T15:04", c.Time, loadTimezone(data.Timezone))
+
+	// I pollini sono disponibili solo in Europa: fuori restano a zero
+	return &AirQuality{
+		Time:        observed,
+		EuropeanAQI: c.EuropeanAQI,
+		Level:       getAirQualityLevel(c.EuropeanAQI),
+		PM25:        c.PM25,
+		PM10:        c.PM10,
+		Ozone:       c.Ozone,
+		NO2:         c.NO2,
+		Pollen: []Pollen{
+			{Name: "Ontano", Value: c.AlderPollen},
+			{Name: "Betulla", Value: c.BirchPollen},
+			{Name: "Graminacee", Value: c.GrassPollen},
+			{Name: "Artemisia", Value: c.MugwortPollen},
+			{Name: "Olivo", Value: c.OlivePollen},
+			{Name: "Ambrosia", Value: c.RagweedPollen},
+		},
+	}, nil
+}
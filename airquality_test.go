@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const airQualityFixture = `{
+	"timezone": "Europe/Rome",
+	"current": {
+		"time": "2024-05-10T12:00",
+		"european_aqi": 45,
+		"pm2_5": 12.5,
+		"pm10": 20,
+		"ozone": 80,
+		"nitrogen_dioxide": 15,
+		"grass_pollen": 30,
+		"olive_pollen": 55
+	}
+}`
+
+// useAirQualityServer punta l'API air-quality sul server di test, con cache
+// vuote e un provider meteo che fallisce sempre
+func useAirQualityServer(t *testing.T, url string) {
+	t.Helper()
+	previousURL, previousAir, previousGeo, previousProvider := airQualityURL, airQualityCache, geocodeCache, weatherProvider
+	t.Cleanup(func() {
+		airQualityURL, airQualityCache, geocodeCache, weatherProvider = previousURL, previousAir, previousGeo, previousProvider
+	})
+
+	airQualityURL = url
+	airQualityCache = newTTLCache[*AirQuality](time.Minute, 0, 10)
+	geocodeCache = newTTLCache[GeoLocation](time.Hour, 0, 10)
+	geocodeCache.set(locationKey(41.9, 12.5), GeoLocation{City: "Roma", Country: "Italia"})
+	weatherProvider = newOpenMeteoProvider(newStatusServer(t, http.StatusServiceUnavailable).URL)
+}
+
+func TestAirQualityHandler(t *testing.T) {
+	useAirQualityServer(t, newFixtureServer(t, airQualityFixture, nil).URL)
+
+	// La previsione non disponibile non deve impedire la risposta
+	rec := httptest.NewRecorder()
+	airQualityHandler(rec, httptest.NewRequest(http.MethodGet, "/airquality?lat=41.9&lon=12.5", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	var got AirQualityResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.City != "Roma" || got.Country != "Italia" || got.Lat != 41.9 || got.Lon != 12.5 {
+		t.Errorf("location = %s, %s (%v, %v)", got.City, got.Country, got.Lat, got.Lon)
+	}
+	if got.AirQuality == nil {
+		t.Fatal("air_quality missing")
+	}
+	if got.AirQuality.EuropeanAQI != 45 || got.AirQuality.Level != getAirQualityLevel(45) {
+		t.Errorf("AQI = %v (%s)", got.AirQuality.EuropeanAQI, got.AirQuality.Level)
+	}
+	if top := got.AirQuality.MaxPollen(); top.Value != 55 {
+		t.Errorf("MaxPollen = %+v, want 55", top)
+	}
+}
+
+func TestAirQualityHandlerUpstreamError(t *testing.T) {
+	useAirQualityServer(t, newStatusServer(t, http.StatusInternalServerError).URL)
+
+	rec := httptest.NewRecorder()
+	airQualityHandler(rec, httptest.NewRequest(http.MethodGet, "/airquality?lat=41.9&lon=12.5", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502", rec.Code)
+	}
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultAlertCooldown è l'attesa minima tra due avvisi della stessa regola
+const defaultAlertCooldown = 180
+
+// errAlertRuleNotFound indica un identificativo di regola inesistente
+var errAlertRuleNotFound = fmt.Errorf("regola non trovata")
+
+// alertMetric descrive una grandezza valutabile dalle regole di avviso
+type alertMetric struct {
+	Label string
+	unit  func(u Units) string
+	// current legge il valore attuale; false se non disponibile
+	current func(d *WeatherData) (float64, bool)
+	// hourly legge il valore di un'ora della previsione; nil se la metrica
+	// esiste solo per le condizioni attuali
+	hourly func(h HourForecast) float64
+	// toUnits e fromUnits convertono la soglia dalle unità metriche a quelle
+	// configurate e viceversa; nil se la metrica non ha unità
+	toUnits   func(u Units, value float64) float64
+	fromUnits func(u Units, value float64) float64
+}
+
+// alertMetrics sono le metriche disponibili; le soglie sono salvate in unità
+// metriche (°C, mm, km/h) e convertite in quelle configurate
+var alertMetrics = map[string]alertMetric{
+	"temperature": {
+		Label:     "🌡️ Temperatura",
+		unit:      Units.TemperatureLabel,
+		current:   func(d *WeatherData) (float64, bool) { return d.CurrentTemp, true },
+		hourly:    func(h HourForecast) float64 { return h.Temperature },
+		toUnits:   Units.temperature,
+		fromUnits: Units.celsius,
+	},
+	"feels_like": {
+		Label:     "🌡️ Temperatura percepita",
+		unit:      Units.TemperatureLabel,
+		current:   func(d *WeatherData) (float64, bool) { return d.FeelsLike, true },
+		hourly:    func(h HourForecast) float64 { return h.ApparentTemperature },
+		toUnits:   Units.temperature,
+		fromUnits: Units.celsius,
+	},
+	"humidity": {
+		Label:   "💧 Umidità",
+		unit:    func(Units) string { return "%" },
+		current: func(d *WeatherData) (float64, bool) { return d.Humidity, true },
+		hourly:  func(h HourForecast) float64 { return h.Humidity },
+	},
+	"wind_speed": {
+		Label:     "💨 Vento",
+		unit:      func(u Units) string { return " " + u.WindLabel() },
+		current:   func(d *WeatherData) (float64, bool) { return d.WindSpeed, true },
+		hourly:    func(h HourForecast) float64 { return h.WindSpeed },
+		toUnits:   Units.wind,
+		fromUnits: Units.kmh,
+	},
+	"precipitation": {
+		Label:     "🌧️ Precipitazioni",
+		unit:      func(u Units) string { return " " + u.PrecipitationLabel() },
+		current:   func(d *WeatherData) (float64, bool) { return d.Precipitation, true },
+		hourly:    func(h HourForecast) float64 { return h.Precipitation },
+		toUnits:   Units.precipitation,
+		fromUnits: Units.millimeters,
+	},
+	"precipitation_probability": {
+		Label: "☔ Probabilità di pioggia",
+		unit:  func(Units) string { return "%" },
+		current: func(d *WeatherData) (float64, bool) {
+			if len(d.Hours) == 0 {
+				return 0, false
+			}
+			return d.Hours[0].PrecipitationProbability, true
+		},
+		hourly: func(h HourForecast) float64 { return h.PrecipitationProbability },
+	},
+	"uv_index": {
+		Label:   "🔆 UV max di oggi",
+		unit:    func(Units) string { return "" },
+		current: func(d *WeatherData) (float64, bool) { return d.UVIndex, true },
+	},
+	"aqi": {
+		Label: "🌫️ AQI europeo",
+		unit:  func(Units) string { return "" },
+		current: func(d *WeatherData) (float64, bool) {
+			if d.AirQuality == nil {
+				return 0, false
+			}
+			return d.AirQuality.EuropeanAQI, true
+		},
+	},
+	"condition": {
+		Label:   "Condizione",
+		unit:    func(Units) string { return "" },
+		current: func(d *WeatherData) (float64, bool) { return float64(d.WeatherCode), true },
+		hourly:  func(h HourForecast) float64 { return float64(h.WeatherCode) },
+	},
+}
+
+// alertConditions raggruppa i codici WMO nelle condizioni usate dalle regole
+var alertConditions = map[string]struct {
+	Label string
+	match func(code int) bool
+}{
+	"clear":        {"☀️ Sereno", func(c int) bool { return c <= 1 }},
+	"cloudy":       {"☁️ Nuvoloso", func(c int) bool { return c == 2 || c == 3 }},
+	"fog":          {"🌫️ Nebbia", func(c int) bool { return c == 45 || c == 48 }},
+	"drizzle":      {"🌦️ Pioviggine", func(c int) bool { return c >= 51 && c <= 57 }},
+	"rain":         {"🌧️ Pioggia", func(c int) bool { return (c >= 61 && c <= 67) || (c >= 80 && c <= 82) }},
+	"snow":         {"❄️ Neve", func(c int) bool { return (c >= 71 && c <= 77) || c == 85 || c == 86 }},
+	"thunderstorm": {"⛈️ Temporale", func(c int) bool { return c >= 95 && c <= 99 }},
+}
+
+// AlertRule è una condizione sul meteo che, quando verificata, invia un avviso
+type AlertRule struct {
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	Metric          string    `json:"metric"`
+	Operator        string    `json:"operator"`            // <, <=, >, >=, =, != (solo = e != per condition)
+	Value           float64   `json:"value"`               // soglia in unità metriche
+	Condition       string    `json:"condition,omitempty"` // gruppo di condizioni per la metrica condition
+	WithinHours     int       `json:"within_hours"`        // 0 = condizioni attuali, N = prossime N ore
+	CooldownMinutes int       `json:"cooldown_minutes"`
+	Enabled         bool      `json:"enabled"`
+	LastFired       time.Time `json:"last_fired,omitzero"`
+}
+
+// alertRulePayload è una regola come scambiata con l'API e l'interfaccia:
+// la soglia è nelle unità configurate e, se enabled manca, la regola è attiva
+type alertRulePayload struct {
+	AlertRule
+	Value   float64 `json:"value"`
+	Enabled *bool   `json:"enabled"`
+}
+
+// payload converte la soglia della regola nelle unità u
+func (r AlertRule) payload(u Units) alertRulePayload {
+	enabled := r.Enabled
+	return alertRulePayload{AlertRule: r, Value: r.displayThreshold(u), Enabled: &enabled}
+}
+
+// rule riporta in unità metriche la soglia espressa nelle unità u
+func (p alertRulePayload) rule(u Units) AlertRule {
+	r := p.AlertRule
+	r.Value = p.Value
+	if metric, ok := alertMetrics[r.Metric]; ok && metric.fromUnits != nil {
+		r.Value = metric.fromUnits(u, p.Value)
+	}
+	r.Enabled = p.Enabled == nil || *p.Enabled
+	return r
+}
+
+// threshold restituisce la soglia nelle unità u
+func (r AlertRule) threshold(u Units) float64 {
+	if metric, ok := alertMetrics[r.Metric]; ok && metric.toUnits != nil {
+		return metric.toUnits(u, r.Value)
+	}
+	return r.Value
+}
+
+// displayThreshold restituisce la soglia nelle unità u, arrotondata per la
+// lettura così che le conversioni non mostrino valori come 32.000000000000004
+func (r AlertRule) displayThreshold(u Units) float64 {
+	return math.Round(r.threshold(u)*100) / 100
+}
+
+// AlertEvent è una regola scattata, con il valore e l'ora che l'hanno fatta scattare
+type AlertEvent struct {
+	RuleID  string    `json:"rule_id"`
+	Name    string    `json:"name"`
+	Message string    `json:"message"`
+	Value   float64   `json:"value"`
+	At      time.Time `json:"at"`
+}
+
+// validate controlla metrica, operatore e finestra, completando i default
+func (r *AlertRule) validate() error {
+	metric, ok := alertMetrics[r.Metric]
+	if !ok {
+		return fmt.Errorf("metrica sconosciuta: %q", r.Metric)
+	}
+	switch r.Operator {
+	case "<", "<=", ">", ">=", "=", "!=":
+	default:
+		return fmt.Errorf("operatore non valido: %q", r.Operator)
+	}
+	if r.Metric == "condition" {
+		if _, ok := alertConditions[r.Condition]; !ok {
+			return fmt.Errorf("condizione sconosciuta: %q", r.Condition)
+		}
+		if r.Operator != "=" && r.Operator != "!=" {
+			return fmt.Errorf("per le condizioni si usano solo = e !=")
+		}
+	} else {
+		r.Condition = ""
+	}
+	if r.WithinHours < 0 || r.WithinHours > hourlyTimelineHours {
+		return fmt.Errorf("finestra non valida: da 0 a %d ore", hourlyTimelineHours)
+	}
+	if r.WithinHours > 0 && metric.hourly == nil {
+		return fmt.Errorf("%s è disponibile solo per le condizioni attuali", metric.Label)
+	}
+	if r.CooldownMinutes <= 0 {
+		r.CooldownMinutes = defaultAlertCooldown
+	}
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		r.Name = r.Description(currentUnits())
+	}
+	return nil
+}
+
+// Description descrive la regola, ad esempio "🌡️ Temperatura < 0°C nelle prossime 12 ore"
+func (r AlertRule) Description(u Units) string {
+	metric := alertMetrics[r.Metric]
+	var text string
+	if r.Metric == "condition" {
+		verb := "è"
+		if r.Operator == "!=" {
+			verb = "non è"
+		}
+		text = fmt.Sprintf("Condizione %s %s", verb, alertConditions[r.Condition].Label)
+	} else {
+		text = fmt.Sprintf("%s %s %g%s", metric.Label, r.Operator, r.displayThreshold(u), metric.unit(u))
+	}
+	if r.WithinHours > 0 {
+		return fmt.Sprintf("%s nelle prossime %d ore", text, r.WithinHours)
+	}
+	return text + " adesso"
+}
+
+// matches confronta un valore con la soglia o la condizione della regola;
+// valore e soglia devono essere nelle stesse unità
+func (r AlertRule) matches(value float64) bool {
+	if r.Metric == "condition" {
+		// Con un codice sconosciuto non si può dire né = né !=
+		if int(value) == unknownWeatherCode {
+			return false
+		}
+		match := alertConditions[r.Condition].match(int(value))
+		return match == (r.Operator == "=")
+	}
+	switch r.Operator {
+	case "<":
+		return value < r.Value
+	case "<=":
+		return value <= r.Value
+	case ">":
+		return value > r.Value
+	case ">=":
+		return value >= r.Value
+	case "=":
+		return value == r.Value
+	case "!=":
+		return value != r.Value
+	}
+	return false
+}
+
+// evaluate verifica la regola sui dati meteo: sulle condizioni attuali o sulla
+// prima ora della finestra di previsione in cui la condizione è vera
+func (r AlertRule) evaluate(d *WeatherData) (AlertEvent, bool) {
+	metric := alertMetrics[r.Metric]
+	event := AlertEvent{RuleID: r.ID, Name: r.Name}
+	// I dati sono nelle unità configurate: la soglia va convertita allo stesso modo
+	r.Value = r.threshold(d.Units)
+
+	describe := func(value float64) string {
+		if r.Metric == "condition" {
+			return getWeatherDescription(int(value))
+		}
+		return fmt.Sprintf("%s %.1f%s", metric.Label, value, metric.unit(d.Units))
+	}
+
+	if r.WithinHours == 0 {
+		value, ok := metric.current(d)
+		if !ok || !r.matches(value) {
+			return event, false
+		}
+		event.Value, event.At = value, d.ObservedAt
+		event.Message = describe(value) + " adesso"
+		return event, true
+	}
+
+	hours := d.Hours[:min(r.WithinHours, len(d.Hours))]
+	for _, h := range hours {
+		value := metric.hourly(h)
+		if r.matches(value) {
+			event.Value, event.At = value, h.Time
+			event.Message = fmt.Sprintf("%s alle %s", describe(value), h.Time.Format("15:04"))
+			if h.Time.YearDay() != hours[0].Time.YearDay() {
+				event.Message += " di domani"
+			}
+			return event, true
+		}
+	}
+	return event, false
+}
+
+// listAlertRules restituisce una copia delle regole di avviso
+func listAlertRules() []AlertRule {
+	alertMutex.RLock()
+	defer alertMutex.RUnlock()
+	return append([]AlertRule{}, alertRules...)
+}
+
+// alertRulePayloads restituisce le regole con le soglie nelle unità u
+func alertRulePayloads(u Units) []alertRulePayload {
+	rules := listAlertRules()
+	list := make([]alertRulePayload, len(rules))
+	for i, r := range rules {
+		list[i] = r.payload(u)
+	}
+	return list
+}
+
+// hasActiveAlertRules indica se almeno una regola è attiva: in tal caso le
+// notifiche periodiche inviano solo gli avvisi al posto del riepilogo
+func hasActiveAlertRules() bool {
+	alertMutex.RLock()
+	defer alertMutex.RUnlock()
+	for _, r := range alertRules {
+		if r.Enabled {
+			return true
+		}
+	}
+	return false
+}
+
+// findAlertRule cerca una regola; va chiamata con alertMutex acquisito
+func findAlertRule(id string) (int, bool) {
+	for i, r := range alertRules {
+		if r.ID == id {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// addAlertRule aggiunge una regola e le assegna un identificativo
+func addAlertRule(r AlertRule) (AlertRule, error) {
+	if err := r.validate(); err != nil {
+		return r, err
+	}
+
+	alertMutex.Lock()
+	defer alertMutex.Unlock()
+
+	nextAlertRuleID++
+	r.ID = strconv.Itoa(nextAlertRuleID)
+	r.LastFired = time.Time{}
+	alertRules = append(alertRules, r)
+	return r, nil
+}
+
+// updateAlertRule modifica una regola esistente, mantenendo l'ultimo invio
+func updateAlertRule(r AlertRule) (AlertRule, error) {
+	if err := r.validate(); err != nil {
+		return r, err
+	}
+
+	alertMutex.Lock()
+	defer alertMutex.Unlock()
+
+	i, ok := findAlertRule(r.ID)
+	if !ok {
+		return r, errAlertRuleNotFound
+	}
+	r.LastFired = alertRules[i].LastFired
+	alertRules[i] = r
+	return r, nil
+}
+
+// deleteAlertRule rimuove una regola
+func deleteAlertRule(id string) error {
+	alertMutex.Lock()
+	defer alertMutex.Unlock()
+
+	i, ok := findAlertRule(id)
+	if !ok {
+		return errAlertRuleNotFound
+	}
+	alertRules = append(alertRules[:i], alertRules[i+1:]...)
+	return nil
+}
+
+// evaluateAlerts restituisce gli avvisi delle regole attive verificate e
+// non più in attesa del cooldown
+func evaluateAlerts(d *WeatherData, now time.Time) []AlertEvent {
+	alertMutex.RLock()
+	defer alertMutex.RUnlock()
+
+	var events []AlertEvent
+	for _, r := range alertRules {
+		if !r.Enabled {
+			continue
+		}
+		if now.Before(r.LastFired.Add(time.Duration(r.CooldownMinutes) * time.Minute)) {
+			continue
+		}
+		if event, ok := r.evaluate(d); ok {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// markAlertsFired registra l'invio degli avvisi, che fa partire il cooldown
+func markAlertsFired(events []AlertEvent, now time.Time) {
+	alertMutex.Lock()
+	defer alertMutex.Unlock()
+
+	for _, event := range events {
+		if i, ok := findAlertRule(event.RuleID); ok {
+			alertRules[i].LastFired = now
+		}
+	}
+}
+
+// newAlertNotification prepara la notifica con gli avvisi scattati
+func newAlertNotification(data *WeatherData, events []AlertEvent) Notification {
+	summary := weatherSummary{
+		Title:    "⚠️ Avviso meteo " + data.City,
+		Subtitle: fmt.Sprintf("🕐 %s (%s)", data.Time, data.Timezone),
+	}
+	for _, event := range events {
+		summary.Sections = append(summary.Sections, summarySection{
+			Title: event.Name,
+			Lines: []string{event.Message},
+		})
+	}
+	return Notification{Event: eventAlert, Summary: summary, Data: data, Alerts: events}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withAlertRules sostituisce le regole globali per la durata del test
+func withAlertRules(t *testing.T, rules ...AlertRule) {
+	t.Helper()
+	alertMutex.Lock()
+	previous, previousID := alertRules, nextAlertRuleID
+	alertRules, nextAlertRuleID = rules, len(rules)
+	alertMutex.Unlock()
+	t.Cleanup(func() {
+		alertMutex.Lock()
+		alertRules, nextAlertRuleID = previous, previousID
+		alertMutex.Unlock()
+	})
+}
+
+// withUnits imposta le unità configurate per la durata del test
+func withUnits(t *testing.T, u Units) {
+	t.Helper()
+	configMutex.Lock()
+	previous := units
+	units = u.normalize()
+	configMutex.Unlock()
+	t.Cleanup(func() {
+		configMutex.Lock()
+		units = previous
+		configMutex.Unlock()
+	})
+}
+
+// alertWeatherData restituisce dati con tre ore di previsione, l'ultima domani
+func alertWeatherData() *WeatherData {
+	d := testWeatherData()
+	start := time.Date(2026, 7, 1, 22, 0, 0, 0, time.UTC)
+	d.Hours = []HourForecast{
+		{Time: start, Temperature: 20, WeatherCode: 3, WindSpeed: 10, PrecipitationProbability: 10},
+		{Time: start.Add(time.Hour), Temperature: 18, WeatherCode: 61, WindSpeed: 30, PrecipitationProbability: 70},
+		{Time: start.Add(2 * time.Hour), Temperature: -1, WeatherCode: 95, WindSpeed: 55, PrecipitationProbability: 90},
+	}
+	return d
+}
+
+func TestAlertRuleMatches(t *testing.T) {
+	for _, c := range []struct {
+		rule  AlertRule
+		value float64
+		want  bool
+	}{
+		{AlertRule{Metric: "temperature", Operator: "<", Value: 0}, -0.5, true},
+		{AlertRule{Metric: "temperature", Operator: "<", Value: 0}, 0, false},
+		{AlertRule{Metric: "temperature", Operator: "<=", Value: 0}, 0, true},
+		{AlertRule{Metric: "wind_speed", Operator: ">", Value: 50}, 50, false},
+		{AlertRule{Metric: "wind_speed", Operator: ">=", Value: 50}, 50, true},
+		{AlertRule{Metric: "humidity", Operator: "=", Value: 80}, 80, true},
+		{AlertRule{Metric: "humidity", Operator: "!=", Value: 80}, 80, false},
+		{AlertRule{Metric: "condition", Operator: "=", Condition: "rain"}, 63, true},
+		{AlertRule{Metric: "condition", Operator: "=", Condition: "rain"}, 81, true},
+		{AlertRule{Metric: "condition", Operator: "=", Condition: "rain"}, 71, false},
+		{AlertRule{Metric: "condition", Operator: "!=", Condition: "clear"}, 3, true},
+		{AlertRule{Metric: "condition", Operator: "!=", Condition: "clear"}, 1, false},
+		// Un simbolo senza equivalente WMO non è sereno, ma nemmeno il contrario
+		{AlertRule{Metric: "condition", Operator: "=", Condition: "clear"}, unknownWeatherCode, false},
+		{AlertRule{Metric: "condition", Operator: "!=", Condition: "clear"}, unknownWeatherCode, false},
+		{AlertRule{Metric: "temperature", Operator: "~", Value: 0}, 0, false},
+	} {
+		if got := c.rule.matches(c.value); got != c.want {
+			t.Errorf("%s %s %g/%s con %g = %v, want %v", c.rule.Metric, c.rule.Operator, c.rule.Value, c.rule.Condition, c.value, got, c.want)
+		}
+	}
+}
+
+func TestAlertRuleEvaluate(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		rule    AlertRule
+		units   Units
+		want    bool
+		value   float64
+		message string
+	}{
+		{"attuale verificata", AlertRule{Metric: "temperature", Operator: ">", Value: 24}, Units{}, true, 24.5, "🌡️ Temperatura 24.5°C adesso"},
+		{"attuale non verificata", AlertRule{Metric: "temperature", Operator: ">", Value: 30}, Units{}, false, 0, ""},
+		{"prima ora nella finestra", AlertRule{Metric: "precipitation_probability", Operator: ">=", Value: 50, WithinHours: 3}, Units{}, true, 70, "☔ Probabilità di pioggia 70.0% alle 23:00"},
+		{"ora di domani", AlertRule{Metric: "temperature", Operator: "<", Value: 0, WithinHours: 12}, Units{}, true, -1, "🌡️ Temperatura -1.0°C alle 00:00 di domani"},
+		{"fuori dalla finestra", AlertRule{Metric: "temperature", Operator: "<", Value: 0, WithinHours: 2}, Units{}, false, 0, ""},
+		{"condizione", AlertRule{Metric: "condition", Operator: "=", Condition: "thunderstorm", WithinHours: 3}, Units{}, true, 95, "⛈️ Temporale alle 00:00 di domani"},
+		{"AQI non disponibile", AlertRule{Metric: "aqi", Operator: ">", Value: 0}, Units{}, false, 0, ""},
+		// Soglia in km/h, dati in nodi: 50 km/h sono circa 27 nodi
+		{"soglia convertita", AlertRule{Metric: "wind_speed", Operator: ">", Value: 50, WithinHours: 3}, Units{Wind: windKnots}, true, 30, "💨 Vento 30.0 kn alle 23:00"},
+		{"soglia convertita in Beaufort", AlertRule{Metric: "wind_speed", Operator: ">=", Value: 50, WithinHours: 3}, Units{Wind: windBeaufort}, true, 7, "💨 Vento 7.0 Bft alle 00:00 di domani"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			d := alertWeatherData()
+			d.Units = c.units.normalize()
+			if c.units.Wind == windBeaufort {
+				// Con Beaufort i dati sono gradi: 50 km/h sono il grado 7, 30 km/h il 5
+				for i := range d.Hours {
+					d.Hours[i].WindSpeed = beaufort(d.Hours[i].WindSpeed)
+				}
+			}
+			c.rule.ID, c.rule.Name = "1", "regola"
+
+			event, ok := c.rule.evaluate(d)
+			if ok != c.want {
+				t.Fatalf("evaluate = %v, want %v (%+v)", ok, c.want, event)
+			}
+			if !ok {
+				return
+			}
+			if event.RuleID != "1" || event.Name != "regola" || event.Value != c.value || event.Message != c.message {
+				t.Errorf("event = %+v, want value %g message %q", event, c.value, c.message)
+			}
+		})
+	}
+}
+
+func TestEvaluateAlertsCooldown(t *testing.T) {
+	now := time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC)
+	hot := AlertRule{ID: "1", Metric: "temperature", Operator: ">", Value: 20, CooldownMinutes: 60, Enabled: true}
+	withAlertRules(t,
+		hot,
+		AlertRule{ID: "2", Metric: "temperature", Operator: ">", Value: 20, CooldownMinutes: 60},
+		AlertRule{ID: "3", Metric: "temperature", Operator: ">", Value: 30, CooldownMinutes: 60, Enabled: true},
+	)
+
+	ids := func(events []AlertEvent) string {
+		var list []string
+		for _, e := range events {
+			list = append(list, e.RuleID)
+		}
+		return strings.Join(list, ",")
+	}
+
+	// Scattano solo le regole attive e verificate
+	events := evaluateAlerts(alertWeatherData(), now)
+	if got := ids(events); got != "1" {
+		t.Fatalf("events = %s, want 1", got)
+	}
+
+	// Dopo l'invio la regola tace fino alla fine della pausa
+	markAlertsFired(events, now)
+	if got := ids(evaluateAlerts(alertWeatherData(), now.Add(59*time.Minute))); got != "" {
+		t.Errorf("events during cooldown = %s", got)
+	}
+	if got := ids(evaluateAlerts(alertWeatherData(), now.Add(60*time.Minute))); got != "1" {
+		t.Errorf("events after cooldown = %s, want 1", got)
+	}
+	if rules := listAlertRules(); !rules[0].LastFired.Equal(now) || !rules[1].LastFired.IsZero() {
+		t.Errorf("last fired = %v / %v", rules[0].LastFired, rules[1].LastFired)
+	}
+}
+
+func TestAlertRuleValidate(t *testing.T) {
+	withUnits(t, Units{})
+	for _, c := range []struct {
+		name string
+		rule AlertRule
+		err  string
+	}{
+		{"metrica sconosciuta", AlertRule{Metric: "pressure", Operator: "<"}, "metrica sconosciuta"},
+		{"operatore non valido", AlertRule{Metric: "temperature", Operator: "=>"}, "operatore non valido"},
+		{"condizione sconosciuta", AlertRule{Metric: "condition", Operator: "=", Condition: "hail"}, "condizione sconosciuta"},
+		{"operatore per condizione", AlertRule{Metric: "condition", Operator: "<", Condition: "rain"}, "solo = e !="},
+		{"finestra negativa", AlertRule{Metric: "temperature", Operator: "<", WithinHours: -1}, "finestra non valida"},
+		{"finestra troppo lunga", AlertRule{Metric: "temperature", Operator: "<", WithinHours: hourlyTimelineHours + 1}, "finestra non valida"},
+		{"solo attuale", AlertRule{Metric: "uv_index", Operator: ">", WithinHours: 3}, "solo per le condizioni attuali"},
+	} {
+		if err := c.rule.validate(); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: validate = %v, want %q", c.name, err, c.err)
+		}
+	}
+
+	// I default completano nome e pausa; la condizione vale solo per la metrica condition
+	r := AlertRule{Metric: "temperature", Operator: "<", Value: 0, WithinHours: 12, Condition: "rain", Name: "  "}
+	if err := r.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if r.Name != "🌡️ Temperatura < 0°C nelle prossime 12 ore" || r.CooldownMinutes != defaultAlertCooldown || r.Condition != "" {
+		t.Errorf("rule = %+v", r)
+	}
+	r = AlertRule{Metric: "condition", Operator: "!=", Condition: "clear", Name: " Nuvole "}
+	if err := r.validate(); err != nil || r.Name != "Nuvole" {
+		t.Errorf("rule = %+v, %v", r, err)
+	}
+}
+
+func TestAlertRuleUnits(t *testing.T) {
+	imperial := Units{System: unitsImperial, Wind: windMph}.normalize()
+
+	// La soglia inserita in °F viene salvata in °C e mostrata di nuovo in °F
+	p := alertRulePayload{AlertRule: AlertRule{Metric: "temperature", Operator: "<"}, Value: 32}
+	r := p.rule(imperial)
+	if r.Value != 0 {
+		t.Errorf("stored value = %g, want 0", r.Value)
+	}
+	if got := r.payload(imperial).Value; got != 32 {
+		t.Errorf("imperial value = %g, want 32", got)
+	}
+	if got := r.payload(Units{}.normalize()).Value; got != 0 {
+		t.Errorf("metric value = %g, want 0", got)
+	}
+	if got := r.Description(imperial); got != "🌡️ Temperatura < 32°F adesso" {
+		t.Errorf("Description = %q", got)
+	}
+
+	// Cambiare unità non cambia il significato della soglia
+	for _, c := range []struct {
+		metric string
+		units  Units
+		value  float64
+		want   float64
+	}{
+		{"wind_speed", Units{Wind: windMs}, 10, 36},
+		{"wind_speed", Units{Wind: windKnots}, 10, 18.52},
+		{"wind_speed", Units{Wind: windBeaufort}, 7, 50},
+		{"wind_speed", Units{Wind: windBeaufort}, 0, 0},
+		{"precipitation", Units{System: unitsImperial}, 1, 25.4},
+		{"humidity", Units{System: unitsImperial}, 80, 80},
+	} {
+		u := c.units.normalize()
+		p := alertRulePayload{AlertRule: AlertRule{Metric: c.metric}, Value: c.value}
+		r := p.rule(u)
+		if r.Value < c.want-1e-9 || r.Value > c.want+1e-9 {
+			t.Errorf("%s in %+v: stored %g, want %g", c.metric, u, r.Value, c.want)
+		}
+		if got := r.payload(u).Value; got != c.value {
+			t.Errorf("%s in %+v: round trip %g, want %g", c.metric, u, got, c.value)
+		}
+	}
+}
+
+func TestAlertRulePayloadEnabled(t *testing.T) {
+	var p alertRulePayload
+	if err := json.Unmarshal([]byte(`{"metric": "temperature", "operator": "<", "value": 0}`), &p); err != nil {
+		t.Fatal(err)
+	}
+	if !p.rule(Units{}).Enabled {
+		t.Error("rule without enabled should be active")
+	}
+	if err := json.Unmarshal([]byte(`{"metric": "temperature", "operator": "<", "value": 0, "enabled": false}`), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.rule(Units{}).Enabled {
+		t.Error("enabled false ignored")
+	}
+
+	// La risposta riporta enabled e la soglia nelle unità configurate
+	data, err := json.Marshal(AlertRule{ID: "1", Metric: "temperature", Value: 100}.payload(Units{System: unitsImperial}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out["value"] != 212.0 || out["enabled"] != false || out["id"] != "1" {
+		t.Errorf("payload = %s", data)
+	}
+}
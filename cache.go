@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// cacheEntry è un valore in cache con il momento in cui è stato recuperato
+type cacheEntry[V any] struct {
+	value      V
+	fetchedAt  time.Time
+	refreshing bool
+}
+
+// ttlCache è una cache con scadenza e stale-while-revalidate.
+// Entro ttl il valore è fresco; entro ttl+stale viene restituito
+// subito e aggiornato in background; oltre viene recuperato di nuovo.
+// Oltre maxEntries voci si scartano prima quelle scadute, poi le più vecchie.
+type ttlCache[V any] struct {
+	ttl        time.Duration
+	stale      time.Duration
+	maxEntries int // 0 = illimitata
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry[V]
+
+	hits      uint64
+	staleHits uint64
+	misses    uint64
+	errors    uint64
+	evictions uint64
+}
+
+// newTTLCache crea una cache con la durata, la finestra stale e il numero
+// massimo di voci indicati
+func newTTLCache[V any](ttl, stale time.Duration, maxEntries int) *ttlCache[V] {
+	return &ttlCache[V]{
+		ttl:        ttl,
+		stale:      stale,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*cacheEntry[V]),
+	}
+}
+
+// get restituisce il valore per key, usando fetch quando manca o è scaduto.
+// fetch riceve ctx quando il chiamante attende il risultato; il refresh in
+// background non deve dipendere dalla richiesta originale e riceve un
+// contesto senza cancellazione.
+func (c *ttlCache[V]) get(ctx context.Context, key string, fetch func(context.Context) (V, error)) (V, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok {
+		age := time.Since(entry.fetchedAt)
+		if age < c.ttl {
+			c.hits++
+			value := entry.value
+			c.mu.Unlock()
+			return value, nil
+		}
+		if age < c.ttl+c.stale {
+			c.staleHits++
+			value := entry.value
+			if !entry.refreshing {
+				entry.refreshing = true
+				go c.refresh(context.WithoutCancel(ctx), key, fetch)
+			}
+			c.mu.Unlock()
+			return value, nil
+		}
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	value, err := fetch(ctx)
+	if err != nil {
+		c.mu.Lock()
+		c.errors++
+		c.mu.Unlock()
+		return value, err
+	}
+
+	c.set(key, value)
+	return value, nil
+}
+
+// refresh aggiorna in background un valore stale
+func (c *ttlCache[V]) refresh(ctx context.Context, key string, fetch func(context.Context) (V, error)) {
+	value, err := fetch(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		c.errors++
+		if entry, ok := c.entries[key]; ok {
+			entry.refreshing = false
+		}
+		log.Printf("⚠️ Aggiornamento cache fallito per %s: %v", key, err)
+		return
+	}
+	c.storeLocked(key, value)
+}
+
+// set memorizza un valore appena recuperato
+func (c *ttlCache[V]) set(key string, value V) {
+	c.mu.Lock()
+	c.storeLocked(key, value)
+	c.mu.Unlock()
+}
+
+// storeLocked inserisce il valore e, oltre maxEntries, libera spazio
+// scartando prima le voci scadute e poi le meno recenti
+func (c *ttlCache[V]) storeLocked(key string, value V) {
+	now := time.Now()
+	c.entries[key] = &cacheEntry[V]{value: value, fetchedAt: now}
+	if c.maxEntries <= 0 || len(c.entries) <= c.maxEntries {
+		return
+	}
+
+	for k, entry := range c.entries {
+		if now.Sub(entry.fetchedAt) >= c.ttl+c.stale {
+			delete(c.entries, k)
+			c.evictions++
+		}
+	}
+	for len(c.entries) > c.maxEntries {
+		oldest := ""
+		var oldestAt time.Time
+		for k, entry := range c.entries {
+			if k != key && (oldest == "" || entry.fetchedAt.Before(oldestAt)) {
+				oldest, oldestAt = k, entry.fetchedAt
+			}
+		}
+		delete(c.entries, oldest)
+		c.evictions++
+	}
+}
+
+// stats restituisce le statistiche correnti della cache
+func (c *ttlCache[V]) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Entries:    len(c.entries),
+		Hits:       c.hits,
+		StaleHits:  c.staleHits,
+		Misses:     c.misses,
+		Errors:     c.errors,
+		Evictions:  c.evictions,
+		MaxEntries: c.maxEntries,
+		TTLSeconds: int(c.ttl / time.Second),
+	}
+}
+
+// diskCache è una cache persistente senza scadenza, salvata come file JSON.
+// Oltre maxEntries voci si scartano le meno recenti; quelle lette dal file
+// all'avvio contano come le più vecchie, in ordine di chiave.
+type diskCache[V any] struct {
+	path       string
+	maxEntries int // 0 = illimitata
+
+	mu      sync.Mutex
+	entries map[string]V
+	order   []string // chiavi dalla meno recente
+}
+
+// openDiskCache carica la cache dal file indicato, se esiste
+func openDiskCache[V any](path string, maxEntries int) (*diskCache[V], error) {
+	c := &diskCache[V]{path: path, maxEntries: maxEntries, entries: make(map[string]V)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		return c, fmt.Errorf("%s: %w", path, err)
+	}
+	c.order = slices.Sorted(maps.Keys(c.entries))
+	c.evictLocked()
+	return c, nil
+}
+
+// lookup restituisce il valore salvato per key
+func (c *diskCache[V]) lookup(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	value, ok := c.entries[key]
+	return value, ok
+}
+
+// store salva il valore e riscrive il file
+func (c *diskCache[V]) store(key string, value V) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = value
+	c.evictLocked()
+
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path, data)
+}
+
+// evictLocked scarta le voci meno recenti oltre maxEntries
+func (c *diskCache[V]) evictLocked() {
+	if c.maxEntries <= 0 || len(c.order) <= c.maxEntries {
+		return
+	}
+	excess := len(c.order) - c.maxEntries
+	for _, key := range c.order[:excess] {
+		delete(c.entries, key)
+	}
+	c.order = slices.Delete(c.order, 0, excess)
+}
+
+// writeFileAtomic scrive su un file temporaneo nella stessa directory e
+// lo rinomina, così un'interruzione non lascia mai un file troncato
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// locationKey arrotonda le coordinate a 2 decimali (circa 1 km)
+func locationKey(lat, lon float64) string {
+	return fmt.Sprintf("%.2f,%.2f", lat, lon)
+}
+
+// cachedProvider aggiunge la cache delle previsioni a un WeatherProvider
+type cachedProvider struct {
+	inner WeatherProvider
+	cache *ttlCache[*Forecast]
+}
+
+// Name restituisce il nome del provider sottostante
+func (p *cachedProvider) Name() string {
+	return p.inner.Name()
+}
+
+// Forecast restituisce la previsione dalla cache o dal provider sottostante
+func (p *cachedProvider) Forecast(ctx context.Context, q ForecastQuery) (*Forecast, error) {
+	key := fmt.Sprintf("%s|%d|%s|%s|%s", locationKey(q.Lat, q.Lon), q.Days, q.Timezone, q.Units.System, q.Units.Wind)
+	return p.cache.get(context.WithoutCancel(ctx), key, func(ctx context.Context) (*Forecast, error) {
+		return p.inner.Forecast(ctx, q)
+	})
+}
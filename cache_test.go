@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// constFetch restituisce un fetch che conta le chiamate
+func constFetch(value string, calls *int) func(context.Context) (string, error) {
+	return func(context.Context) (string, error) {
+		*calls++
+		return value, nil
+	}
+}
+
+func TestTTLCacheHitAndMiss(t *testing.T) {
+	c := newTTLCache[string](time.Hour, 0, 0)
+	var calls int
+	for range 3 {
+		value, err := c.get(context.Background(), "k", constFetch("v", &calls))
+		if err != nil || value != "v" {
+			t.Fatalf("get = %q, %v", value, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("fetch called %d times, want 1", calls)
+	}
+
+	// Gli errori non vengono memorizzati
+	_, err := c.get(context.Background(), "e", func(context.Context) (string, error) {
+		return "", errors.New("boom")
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	stats := c.stats()
+	if stats.Entries != 1 || stats.Hits != 2 || stats.Misses != 2 || stats.Errors != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestTTLCacheStaleRefreshIgnoresCancel(t *testing.T) {
+	c := newTTLCache[string](time.Millisecond, time.Hour, 0)
+	c.set("k", "old")
+	time.Sleep(5 * time.Millisecond)
+
+	// Il refresh in background deve sopravvivere alla fine della richiesta
+	ctx, cancel := context.WithCancel(context.Background())
+	refreshed := make(chan error, 1)
+	value, err := c.get(ctx, "k", func(ctx context.Context) (string, error) {
+		refreshed <- ctx.Err()
+		return "new", nil
+	})
+	cancel()
+	if err != nil || value != "old" {
+		t.Fatalf("stale get = %q, %v, want old", value, err)
+	}
+	if err := <-refreshed; err != nil {
+		t.Errorf("refresh context error = %v", err)
+	}
+}
+
+func TestTTLCacheEviction(t *testing.T) {
+	c := newTTLCache[string](time.Hour, 0, 3)
+	for _, key := range []string{"a", "b", "c"} {
+		c.set(key, key)
+		time.Sleep(time.Millisecond)
+	}
+
+	// Oltre il limite si scarta la voce meno recente
+	c.set("d", "d")
+	if _, ok := c.entries["a"]; ok || len(c.entries) != 3 {
+		t.Errorf("entries = %v, want a evicted", keys(c.entries))
+	}
+
+	// Le voci scadute vengono scartate per prime, anche se non sono le più vecchie
+	c.entries["c"].fetchedAt = time.Now().Add(-2 * time.Hour)
+	c.entries["b"].fetchedAt = time.Now().Add(-30 * time.Minute)
+	c.set("e", "e")
+	if _, ok := c.entries["c"]; ok {
+		t.Errorf("entries = %v, want expired c evicted", keys(c.entries))
+	}
+	if _, ok := c.entries["b"]; !ok {
+		t.Errorf("entries = %v, want b kept", keys(c.entries))
+	}
+	if stats := c.stats(); stats.Entries != 3 || stats.Evictions != 2 || stats.MaxEntries != 3 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	// Aggiornare una chiave esistente non scarta nulla
+	c.set("e", "e2")
+	if stats := c.stats(); stats.Entries != 3 || stats.Evictions != 2 {
+		t.Errorf("stats after update = %+v", stats)
+	}
+}
+
+func TestTTLCacheUnlimited(t *testing.T) {
+	c := newTTLCache[int](time.Hour, 0, 0)
+	for i := range 100 {
+		c.set(string(rune('A'+i)), i)
+	}
+	if stats := c.stats(); stats.Entries != 100 || stats.Evictions != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestDiskCacheEviction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	c, err := openDiskCache[GeoLocation](path, 2)
+	if err != nil {
+		t.Fatalf("openDiskCache: %v", err)
+	}
+	for _, city := range []string{"Roma", "Milano", "Napoli"} {
+		if err := c.store(city, GeoLocation{City: city}); err != nil {
+			t.Fatalf("store: %v", err)
+		}
+	}
+	if _, ok := c.lookup("Roma"); ok {
+		t.Error("Roma should have been evicted")
+	}
+
+	// Il file contiene solo le voci rimaste
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved map[string]GeoLocation
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 2 || saved["Napoli"].City != "Napoli" {
+		t.Errorf("file = %v", saved)
+	}
+
+	// Riaprendo con un limite più basso si scartano le voci in eccesso
+	reopened, err := openDiskCache[GeoLocation](path, 1)
+	if err != nil {
+		t.Fatalf("openDiskCache: %v", err)
+	}
+	if len(reopened.entries) != 1 {
+		t.Errorf("entries after reopen = %v", reopened.entries)
+	}
+	if err := reopened.store("Torino", GeoLocation{City: "Torino"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.lookup("Torino"); !ok || len(reopened.entries) != 1 {
+		t.Errorf("entries = %v, want only Torino", reopened.entries)
+	}
+}
+
+func TestDiskCacheMissingAndInvalidFile(t *testing.T) {
+	dir := t.TempDir()
+	c, err := openDiskCache[GeoLocation](filepath.Join(dir, "missing.json"), 0)
+	if err != nil || c == nil || len(c.entries) != 0 {
+		t.Fatalf("missing file: %v %v", c, err)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err = openDiskCache[GeoLocation](invalid, 0)
+	if err == nil {
+		t.Error("expected error for invalid file")
+	}
+	// La cache resta utilizzabile, vuota
+	if c == nil || c.store("k", GeoLocation{}) != nil {
+		t.Error("cache not usable after invalid file")
+	}
+}
+
+// keys restituisce le chiavi di una mappa, per i messaggi di errore
+func keys[V any](m map[string]V) []string {
+	list := make([]string, 0, len(m))
+	for k := range m {
+		list = append(list, k)
+	}
+	return list
+}
@@ -4,9 +4,11 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/oschwald/maxminddb-golang/v2"
 )
 
 // loadConfig carica la configurazione da .env e imposta i valori di default
@@ -22,6 +24,101 @@ func loadConfig() {
 	telegramBotToken = os.Getenv("TELEGRAM_BOT_TOKEN")
 	telegramChatID = os.Getenv("TELEGRAM_CHAT_ID")
 
+	publicURL = strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+
+	openMeteoURL = os.Getenv("OPENMETEO_URL")
+	metNoURL = os.Getenv("METNO_URL")
+	metNoUserAgent = os.Getenv("METNO_USER_AGENT")
+	airQualityURL = os.Getenv("AIR_QUALITY_URL")
+	archiveURL = os.Getenv("ARCHIVE_URL")
+
+	settingsPath = os.Getenv("SETTINGS_FILE")
+	if settingsPath == "" {
+		settingsPath = "settings.json"
+	}
+	geocodingURL = os.Getenv("GEOCODING_URL")
+	nominatimURL = os.Getenv("NOMINATIM_URL")
+	nominatimUserAgent = os.Getenv("NOMINATIM_USER_AGENT")
+	nominatimEmail = os.Getenv("NOMINATIM_EMAIL")
+	geoNamesFile = os.Getenv("GEONAMES_FILE")
+	geoNamesCountryInfo = os.Getenv("GEONAMES_COUNTRY_INFO")
+	geoNamesMaxKm = float64(envInt("GEONAMES_MAX_KM", 50))
+
+	providerNames := os.Getenv("WEATHER_PROVIDER")
+	if providerNames == "" {
+		providerNames = "openmeteo"
+	}
+	provider, err := newWeatherProvider(providerNames)
+	if err != nil {
+		log.Printf("⚠️ %v, uso openmeteo", err)
+		provider = newOpenMeteoProvider(openMeteoURL)
+	}
+
+	nominatimCachePath := os.Getenv("NOMINATIM_CACHE_FILE")
+	if nominatimCachePath == "" {
+		nominatimCachePath = "nominatim-cache.json"
+	}
+	nominatimCacheSize := envInt("NOMINATIM_CACHE_MAX_ENTRIES", 10000)
+	nominatimDiskCache, err = openDiskCache[GeoLocation](nominatimCachePath, nominatimCacheSize)
+	if err != nil {
+		log.Printf("⚠️ Cache Nominatim non letta, riparto da vuota: %v", err)
+	}
+
+	geocoderNames := os.Getenv("GEOCODER")
+	if geocoderNames == "" {
+		geocoderNames = "openmeteo,nominatim"
+	}
+	geocoder, err = newGeocoder(geocoderNames)
+	if err != nil {
+		log.Printf("⚠️ %v, uso nominatim", err)
+		geocoder = newNominatimGeocoder(nominatimURL, nominatimUserAgent, nominatimEmail)
+	}
+
+	webhooksPath = os.Getenv("WEBHOOKS_FILE")
+	if webhooksPath == "" {
+		webhooksPath = "webhooks.json"
+	}
+	notifierNamesEnv := os.Getenv("NOTIFIERS")
+	if notifierNamesEnv == "" {
+		notifierNamesEnv = "telegram"
+	}
+	notifiers = newNotifiers(notifierNamesEnv)
+	if len(notifiers) == 0 {
+		log.Printf("⚠️ Nessun canale di notifica configurato in NOTIFIERS=%q", notifierNamesEnv)
+	}
+
+	proxies, err := parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Printf("⚠️ %v, X-Forwarded-For ignorato", err)
+	}
+
+	geoIPDB = nil
+	if path := os.Getenv("GEOIP_DB"); path != "" {
+		if geoIPDB, err = maxminddb.Open(path); err != nil {
+			log.Printf("⚠️ Database GeoIP non caricato, uso ip-api: %v", err)
+		} else {
+			log.Printf("🌍 Database GeoIP caricato: %s", path)
+		}
+	}
+
+	forecastTTL := envInt("FORECAST_CACHE_TTL_MINUTES", 10)
+	geocodeTTL := envInt("GEOCODE_CACHE_TTL_HOURS", 24)
+	staleWindow := envInt("CACHE_STALE_MINUTES", 30)
+	stale := time.Duration(staleWindow) * time.Minute
+	maxEntries := envInt("CACHE_MAX_ENTRIES", 1000)
+	// Oltre i 15 minuti dei dati un inizio di pioggia vicino arriverebbe in ritardo
+	nowcastEvery := time.Duration(min(max(envInt("NOWCAST_INTERVAL_MINUTES", 15), 1), 15)) * time.Minute
+
+	forecastCache = newTTLCache[*Forecast](time.Duration(forecastTTL)*time.Minute, stale, maxEntries)
+	geocodeCache = newTTLCache[GeoLocation](time.Duration(geocodeTTL)*time.Hour, stale, maxEntries)
+	ipCache = newTTLCache[GeoLocation](time.Duration(geocodeTTL)*time.Hour, stale, maxEntries)
+	searchCache = newTTLCache[[]GeoLocation](time.Duration(geocodeTTL)*time.Hour, stale, maxEntries)
+	airQualityCache = newTTLCache[*AirQuality](time.Duration(forecastTTL)*time.Minute, stale, maxEntries)
+	// Il nowcast scade prima del controllo successivo e senza finestra stale:
+	// ogni controllo deve vedere la previsione aggiornata
+	nowcastCache = newTTLCache[*Nowcast](nowcastEvery/2, 0, maxEntries)
+	weatherProvider = &cachedProvider{inner: provider, cache: forecastCache}
+
 	intervalMinutes := os.Getenv("NOTIFICATION_INTERVAL_MINUTES")
 	if intervalMinutes == "" {
 		intervalMinutes = "5"
@@ -48,12 +145,66 @@ func loadConfig() {
 		endHour = 18
 	}
 
+	days := envInt("FORECAST_DAYS", 7)
+	if days > 16 {
+		days = 16
+	}
+
+	// Posizione di default, ultimo anello della catena di geolocalizzazione:
+	// senza coordinate si usa Roma, senza nome lo si ricava dalle coordinate
+	defaultPlace := GeoLocation{Lat: 41.9028, Lon: 12.4964, City: "Roma", Country: "Italia"}
+	if os.Getenv("DEFAULT_LAT") != "" || os.Getenv("DEFAULT_LON") != "" {
+		defaultPlace = GeoLocation{
+			Lat: envFloat("DEFAULT_LAT", defaultPlace.Lat),
+			Lon: envFloat("DEFAULT_LON", defaultPlace.Lon),
+		}
+	}
+	if defaultPlace.Lat < -90 || defaultPlace.Lat > 90 || defaultPlace.Lon < -180 || defaultPlace.Lon > 180 {
+		log.Printf("⚠️ DEFAULT_LAT/DEFAULT_LON non validi, uso Roma")
+		defaultPlace = GeoLocation{Lat: 41.9028, Lon: 12.4964, City: "Roma", Country: "Italia"}
+	}
+	if city := os.Getenv("DEFAULT_CITY"); city != "" {
+		defaultPlace.City = city
+	}
+
 	configMutex.Lock()
 	notificationInterval = time.Duration(minutes) * time.Minute
 	notificationStartHour = startHour
 	notificationEndHour = endHour
+	forecastDays = days
+	defaultLocation = defaultPlace
+	trustedProxies = proxies
+	weeklyOutlook = os.Getenv("TELEGRAM_WEEKLY_OUTLOOK") == "true"
+	units = Units{System: os.Getenv("UNITS"), Wind: os.Getenv("WIND_UNIT")}.normalize()
+	airQualityAlertAQI = float64(envInt("AIR_QUALITY_ALERT_AQI", 60))
+	pollenAlertLevel = float64(envInt("POLLEN_ALERT_LEVEL", 50))
+	nowcastAlerts = os.Getenv("NOWCAST_ALERTS") != "false"
+	nowcastLookahead = time.Duration(min(envInt("NOWCAST_LOOKAHEAD_MINUTES", 60), 360)) * time.Minute
+	nowcastInterval = nowcastEvery
+	nowcastWetMM = envFloat("NOWCAST_WET_MM", 0.1)
+	if nowcastWetMM <= 0 {
+		nowcastWetMM = 0.1
+	}
 	configMutex.Unlock()
 
-	log.Printf("✅ Config caricata: port=%s, interval=%dmin, range=%02d-%02d",
-		serverPort, minutes, startHour, endHour)
+	log.Printf("✅ Config caricata: port=%s, interval=%dmin, range=%02d-%02d, provider=%s, geocoder=%s, notifiche=%s, cache=%dmin, giorni=%d, unità=%s/%s",
+		serverPort, minutes, startHour, endHour, weatherProvider.Name(), geocoder.Name(), notifierNames(notifiers), forecastTTL, days, units.System, units.Wind)
+}
+
+// envFloat legge una variabile d'ambiente decimale, con valore di default
+func envFloat(name string, def float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(name), 64)
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// envInt legge una variabile d'ambiente intera positiva, con valore di default
+func envInt(name string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return def
+	}
+	return value
 }
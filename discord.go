@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Limiti dei campi degli embed di Discord
+const (
+	discordTitleMax = 256
+	discordFieldMax = 1024
+	discordColor    = 0x3498db
+)
+
+// discordNotifier invia le notifiche a un canale Discord tramite webhook,
+// con il riepilogo in un embed
+type discordNotifier struct {
+	webhookURL string
+	username   string
+	avatarURL  string
+}
+
+// newDiscordNotifierFromEnv crea il canale Discord dalle variabili DISCORD_*
+func newDiscordNotifierFromEnv() (*discordNotifier, error) {
+	d := &discordNotifier{
+		webhookURL: os.Getenv("DISCORD_WEBHOOK_URL"),
+		username:   os.Getenv("DISCORD_USERNAME"),
+		avatarURL:  os.Getenv("DISCORD_AVATAR_URL"),
+	}
+	if d.webhookURL == "" {
+		return nil, fmt.Errorf("DISCORD_WEBHOOK_URL non impostato")
+	}
+	return d, nil
+}
+
+// Name restituisce il nome del canale
+func (d *discordNotifier) Name() string {
+	return "discord"
+}
+
+// discordField è un campo di un embed
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// discordEmbed è il contenuto formattato di un messaggio Discord
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color"`
+	Fields      []discordField `json:"fields,omitempty"`
+	Timestamp   string         `json:"timestamp,omitempty"`
+	Footer      *discordFooter `json:"footer,omitempty"`
+}
+
+// discordFooter è il piè di pagina di un embed
+type discordFooter struct {
+	Text string `json:"text"`
+}
+
+// Notify invia il riepilogo meteo come embed, una sezione per campo
+func (d *discordNotifier) Notify(ctx context.Context, n Notification) error {
+	summary, data := n.Summary, n.Data
+
+	embed := discordEmbed{
+		Title:       truncateRunes(summary.Title, discordTitleMax),
+		Description: summary.Subtitle,
+		Color:       discordColor,
+	}
+	for _, section := range summary.Sections {
+		embed.Fields = append(embed.Fields, discordField{
+			Name:  truncateRunes(section.Title, discordTitleMax),
+			Value: truncateRunes(strings.Join(section.Lines, "\n"), discordFieldMax),
+		})
+	}
+	if !data.ObservedAt.IsZero() {
+		embed.Timestamp = data.ObservedAt.UTC().Format(time.RFC3339)
+	}
+	if data.Provider != "" {
+		embed.Footer = &discordFooter{Text: "Dati: " + data.Provider}
+	}
+
+	payload := map[string]interface{}{
+		"embeds": []discordEmbed{embed},
+	}
+	if d.username != "" {
+		payload["username"] = d.username
+	}
+	if d.avatarURL != "" {
+		payload["avatar_url"] = d.avatarURL
+	}
+	return postJSON(ctx, d.webhookURL, nil, payload)
+}
+
+// truncateRunes accorcia s a max caratteri, terminando con i puntini
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// discordPayload è il corpo inviato al webhook di Discord
+type discordPayload struct {
+	Username  string         `json:"username"`
+	AvatarURL string         `json:"avatar_url"`
+	Embeds    []discordEmbed `json:"embeds"`
+}
+
+func TestDiscordNotify(t *testing.T) {
+	var payload discordPayload
+	d := &discordNotifier{webhookURL: capturePayload(t, &payload), username: "Meteo", avatarURL: "https://example.com/a.png"}
+
+	n := testNotification()
+	n.Data.Provider = "open-meteo"
+	if err := d.Notify(context.Background(), n); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	if payload.Username != "Meteo" || payload.AvatarURL != "https://example.com/a.png" || len(payload.Embeds) != 1 {
+		t.Fatalf("payload = %+v", payload)
+	}
+	embed := payload.Embeds[0]
+	if embed.Title != "🌤️ Meteo Roma" || embed.Description != "🕐 10:15 - 01/07/2026 (Europe/Rome)" || embed.Color != discordColor {
+		t.Errorf("embed = %+v", embed)
+	}
+	if embed.Timestamp != "2026-07-01T10:15:00Z" {
+		t.Errorf("timestamp = %q", embed.Timestamp)
+	}
+	if embed.Footer == nil || embed.Footer.Text != "Dati: open-meteo" {
+		t.Errorf("footer = %+v", embed.Footer)
+	}
+
+	// Un campo per sezione, con le righe separate da a capo
+	if len(embed.Fields) != 2 {
+		t.Fatalf("fields = %+v", embed.Fields)
+	}
+	if f := embed.Fields[0]; f.Name != "Condizioni Attuali" || !strings.HasPrefix(f.Value, "🌦️ Pioggia leggera\n💧 Umidità: 55%\n") {
+		t.Errorf("field 0 = %+v", f)
+	}
+	if f := embed.Fields[1]; f.Name != "Oggi" || f.Value != "Max: 28.0°C | Min: 18.0°C" {
+		t.Errorf("field 1 = %+v", f)
+	}
+}
+
+func TestDiscordNotifyTruncates(t *testing.T) {
+	var payload discordPayload
+	d := &discordNotifier{webhookURL: capturePayload(t, &payload)}
+
+	// I limiti sono in caratteri, non in byte: si usano caratteri multibyte
+	n := testNotification()
+	n.Summary.Title = strings.Repeat("è", 300)
+	n.Summary.Sections = []summarySection{{
+		Title: strings.Repeat("à", 257),
+		Lines: []string{strings.Repeat("ù", 600), strings.Repeat("ò", 600)},
+	}}
+	if err := d.Notify(context.Background(), n); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	embed := payload.Embeds[0]
+	for _, c := range []struct {
+		name, value string
+		max         int
+	}{
+		{"title", embed.Title, discordTitleMax},
+		{"field name", embed.Fields[0].Name, discordTitleMax},
+		{"field value", embed.Fields[0].Value, discordFieldMax},
+	} {
+		if n := utf8.RuneCountInString(c.value); n != c.max || !strings.HasSuffix(c.value, "…") {
+			t.Errorf("%s: %d caratteri, want %d terminati da …", c.name, n, c.max)
+		}
+	}
+
+	// Senza username, avatar e provider i campi opzionali sono omessi
+	if payload.Username != "" || payload.AvatarURL != "" || embed.Footer != nil {
+		t.Errorf("payload = %+v", payload)
+	}
+}
+
+func TestTruncateRunes(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		max  int
+		want string
+	}{
+		{"ciao", 4, "ciao"},
+		{"ciao", 10, "ciao"},
+		{"ciao mondo", 5, "ciao…"},
+		{"àèìòù", 3, "àè…"},
+	} {
+		if got := truncateRunes(c.in, c.max); got != c.want {
+			t.Errorf("truncateRunes(%q, %d) = %q, want %q", c.in, c.max, got, c.want)
+		}
+	}
+}
+
+func TestDiscordNotifyError(t *testing.T) {
+	srv := newStatusServer(t, http.StatusNotFound)
+	d := &discordNotifier{webhookURL: srv.URL}
+	if err := d.Notify(context.Background(), testNotification()); err == nil || !strings.Contains(err.Error(), "status 404") {
+		t.Errorf("Notify = %v, want status 404", err)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// emailNotifier invia le notifiche via SMTP, con corpo testuale e HTML
+type emailNotifier struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+	to       []string
+	startTLS bool // richiede STARTTLS prima di autenticarsi e inviare
+}
+
+// newEmailNotifierFromEnv crea il canale email dalle variabili SMTP_*
+func newEmailNotifierFromEnv() (*emailNotifier, error) {
+	e := &emailNotifier{
+		host:     os.Getenv("SMTP_HOST"),
+		port:     envInt("SMTP_PORT", 587),
+		username: os.Getenv("SMTP_USERNAME"),
+		password: os.Getenv("SMTP_PASSWORD"),
+		from:     os.Getenv("SMTP_FROM"),
+		startTLS: os.Getenv("SMTP_STARTTLS") != "false",
+	}
+	for _, addr := range strings.Split(os.Getenv("SMTP_TO"), ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			e.to = append(e.to, addr)
+		}
+	}
+	if e.from == "" {
+		e.from = e.username
+	}
+
+	if e.host == "" || len(e.to) == 0 {
+		return nil, fmt.Errorf("SMTP_HOST e SMTP_TO sono obbligatori")
+	}
+	if _, err := mail.ParseAddress(e.from); err != nil {
+		return nil, fmt.Errorf("mittente non valido %q: %w", e.from, err)
+	}
+	for _, addr := range e.to {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return nil, fmt.Errorf("destinatario non valido %q: %w", addr, err)
+		}
+	}
+	return e, nil
+}
+
+// Name restituisce il nome del canale
+func (e *emailNotifier) Name() string {
+	return "email"
+}
+
+// Notify invia il riepilogo meteo come email multipart/alternative
+func (e *emailNotifier) Notify(ctx context.Context, n Notification) error {
+	summary := n.Summary
+	message, err := e.buildMessage(summary, time.Now())
+	if err != nil {
+		return err
+	}
+	return e.send(ctx, message)
+}
+
+// send consegna il messaggio al server SMTP rispettando la scadenza del contesto
+func (e *emailNotifier) send(ctx context.Context, message []byte) error {
+	addr := net.JoinHostPort(e.host, strconv.Itoa(e.port))
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if e.startTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s non supporta STARTTLS (SMTP_STARTTLS=false per inviare in chiaro)", addr)
+		}
+		if err := c.StartTLS(&tls.Config{ServerName: e.host}); err != nil {
+			return err
+		}
+	}
+	if e.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.username, e.password, e.host)); err != nil {
+			return err
+		}
+	}
+
+	from, _ := mail.ParseAddress(e.from)
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, addr := range e.to {
+		to, _ := mail.ParseAddress(addr)
+		if err := c.Rcpt(to.Address); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// emailHTMLTemplate è il corpo HTML della notifica
+var emailHTMLTemplate = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html><body style="font-family:sans-serif;color:#333;">
+<h2>{{.Title}}</h2>
+<p style="color:#666;">{{.Subtitle}}</p>
+{{range .Sections}}<h3 style="margin-bottom:4px;">{{.Title}}</h3>
+<p style="margin-top:0;">{{range $i, $line := .Lines}}{{if $i}}<br>{{end}}{{$line}}{{end}}</p>
+{{end}}</body></html>
+`))
+
+// buildMessage compone l'email con intestazioni e parti testo e HTML
+func (e *emailNotifier) buildMessage(summary weatherSummary, now time.Time) ([]byte, error) {
+	var htmlBody bytes.Buffer
+	if err := emailHTMLTemplate.Execute(&htmlBody, summary); err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", summary.Text()},
+		{"text/html; charset=utf-8", htmlBody.String()},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	// Gli indirizzi sono validati dal costruttore; String codifica i nomi non ASCII
+	from, _ := mail.ParseAddress(e.from)
+	to := make([]string, len(e.to))
+	for i, addr := range e.to {
+		parsed, _ := mail.ParseAddress(addr)
+		to[i] = parsed.String()
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", summary.Title))
+	fmt.Fprintf(&msg, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", parts.Boundary())
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
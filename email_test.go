@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpSession è quanto ricevuto dallo stand-in SMTP in una connessione
+type smtpSession struct {
+	auth []string // credenziali AUTH PLAIN decodificate
+	from string
+	rcpt []string
+	data string
+	cmds []string
+}
+
+// startSMTPServer avvia uno stand-in SMTP minimale su una porta locale, che
+// annuncia STARTTLS solo se richiesto e accetta AUTH PLAIN
+func startSMTPServer(t *testing.T, advertiseStartTLS bool) (port int, sessions <-chan smtpSession) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	ch := make(chan smtpSession, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+		ch <- serveSMTP(textproto.NewConn(conn), advertiseStartTLS)
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, ch
+}
+
+// serveSMTP gestisce una sessione fino a QUIT o alla chiusura del client
+func serveSMTP(c *textproto.Conn, advertiseStartTLS bool) smtpSession {
+	var s smtpSession
+	_ = c.PrintfLine("220 localhost ESMTP stand-in")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return s
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		verb = strings.ToUpper(verb)
+		s.cmds = append(s.cmds, verb)
+
+		switch verb {
+		case "EHLO", "HELO":
+			ext := []string{"localhost", "8BITMIME", "AUTH PLAIN"}
+			if advertiseStartTLS {
+				ext = append(ext, "STARTTLS")
+			}
+			for i, e := range ext {
+				sep := "-"
+				if i == len(ext)-1 {
+					sep = " "
+				}
+				_ = c.PrintfLine("250%s%s", sep, e)
+			}
+		case "AUTH":
+			_, encoded, _ := strings.Cut(arg, " ")
+			decoded, _ := base64.StdEncoding.DecodeString(encoded)
+			s.auth = strings.Split(string(decoded), "\x00")
+			_ = c.PrintfLine("235 2.7.0 Authentication successful")
+		case "MAIL":
+			s.from = strings.TrimPrefix(arg, "FROM:")
+			_ = c.PrintfLine("250 OK")
+		case "RCPT":
+			s.rcpt = append(s.rcpt, strings.TrimPrefix(arg, "TO:"))
+			_ = c.PrintfLine("250 OK")
+		case "DATA":
+			_ = c.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := io.ReadAll(c.DotReader())
+			if err != nil {
+				return s
+			}
+			s.data = string(data)
+			_ = c.PrintfLine("250 OK queued")
+		case "QUIT":
+			_ = c.PrintfLine("221 Bye")
+			return s
+		default:
+			_ = c.PrintfLine("502 command not implemented")
+		}
+	}
+}
+
+// receive attende la sessione registrata dallo stand-in
+func receive(t *testing.T, sessions <-chan smtpSession) smtpSession {
+	t.Helper()
+	select {
+	case s := <-sessions:
+		return s
+	case <-time.After(5 * time.Second):
+		t.Fatal("nessuna sessione SMTP ricevuta")
+		return smtpSession{}
+	}
+}
+
+func TestEmailNotify(t *testing.T) {
+	port, sessions := startSMTPServer(t, false)
+	e := &emailNotifier{
+		host:     "127.0.0.1",
+		port:     port,
+		username: "meteo",
+		password: "segreta",
+		from:     "Meteo Bot <bot@example.com>",
+		to:       []string{"Àlice <alice@example.com>", "bob@example.com"},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := e.Notify(ctx, testNotification()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	s := receive(t, sessions)
+
+	// AUTH PLAIN: identità vuota, utente e password
+	if len(s.auth) != 3 || s.auth[0] != "" || s.auth[1] != "meteo" || s.auth[2] != "segreta" {
+		t.Errorf("auth = %q", s.auth)
+	}
+
+	// L'envelope usa solo gli indirizzi, senza i nomi
+	if s.from != "<bot@example.com>" && !strings.HasPrefix(s.from, "<bot@example.com> ") {
+		t.Errorf("MAIL FROM = %q", s.from)
+	}
+	if len(s.rcpt) != 2 || s.rcpt[0] != "<alice@example.com>" || s.rcpt[1] != "<bob@example.com>" {
+		t.Errorf("RCPT TO = %q", s.rcpt)
+	}
+	if got := s.cmds[len(s.cmds)-1]; got != "QUIT" {
+		t.Errorf("last command = %s, want QUIT", got)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(s.data))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "🌤️ Meteo Roma" {
+		t.Errorf("Subject = %q (%v)", subject, err)
+	}
+	if !strings.Contains(msg.Header.Get("To"), "=?utf-8?") || !strings.Contains(msg.Header.Get("To"), "bob@example.com") {
+		t.Errorf("To = %q, want encoded name", msg.Header.Get("To"))
+	}
+	if msg.Header.Get("MIME-Version") != "1.0" {
+		t.Errorf("MIME-Version = %q", msg.Header.Get("MIME-Version"))
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q (%v)", msg.Header.Get("Content-Type"), err)
+	}
+
+	// NextRawPart lascia intatta la codifica, per verificarla
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	var types []string
+	for {
+		part, err := parts.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextRawPart: %v", err)
+		}
+		raw, _ := io.ReadAll(part)
+		types = append(types, part.Header.Get("Content-Type"))
+
+		if cte := part.Header.Get("Content-Transfer-Encoding"); cte != "quoted-printable" {
+			t.Errorf("Content-Transfer-Encoding = %q", cte)
+		}
+		// DotReader dello stand-in converte CRLF in LF
+		for _, line := range strings.Split(string(raw), "\n") {
+			if line = strings.TrimSuffix(line, "\r"); len(line) > 76 {
+				t.Errorf("riga quoted-printable di %d caratteri", len(line))
+			}
+		}
+		if !strings.Contains(string(raw), "Umidit=C3=A0") {
+			t.Errorf("parte %s non codificata in quoted-printable:\n%s", part.Header.Get("Content-Type"), raw)
+		}
+
+		decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(string(raw))))
+		if err != nil {
+			t.Fatalf("quoted-printable: %v", err)
+		}
+		body := string(decoded)
+		if !strings.Contains(body, "💧 Umidità: 55%") || !strings.Contains(body, "ammessi per riga dal quoted-printable") {
+			t.Errorf("decoded body = %q", body)
+		}
+		if strings.HasPrefix(part.Header.Get("Content-Type"), "text/html") && !strings.Contains(body, "<h3 style=\"margin-bottom:4px;\">Oggi</h3>") {
+			t.Errorf("HTML body = %q", body)
+		}
+	}
+	if len(types) != 2 || types[0] != "text/plain; charset=utf-8" || types[1] != "text/html; charset=utf-8" {
+		t.Errorf("parts = %q, want text then HTML", types)
+	}
+}
+
+func TestEmailNotifyRequiresStartTLS(t *testing.T) {
+	port, sessions := startSMTPServer(t, false)
+	e := &emailNotifier{
+		host:     "127.0.0.1",
+		port:     port,
+		username: "meteo",
+		password: "segreta",
+		from:     "bot@example.com",
+		to:       []string{"alice@example.com"},
+		startTLS: true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := e.Notify(ctx, testNotification())
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("Notify = %v, want STARTTLS refusal", err)
+	}
+
+	// Le credenziali non devono mai viaggiare in chiaro
+	s := receive(t, sessions)
+	if s.auth != nil || s.from != "" || s.data != "" {
+		t.Errorf("session after refusal = %+v", s)
+	}
+}
+
+func TestEmailNotifyHonoursContext(t *testing.T) {
+	// Un server che accetta la connessione ma non risponde mai
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			_, _ = bufio.NewReader(conn).ReadString('\n')
+			conn.Close()
+		}
+	}()
+
+	e := &emailNotifier{
+		host: "127.0.0.1",
+		port: ln.Addr().(*net.TCPAddr).Port,
+		from: "bot@example.com",
+		to:   []string{"alice@example.com"},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := e.Notify(ctx, testNotification()); err == nil {
+		t.Fatal("Notify: expected timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Notify took %v", elapsed)
+	}
+}
+
+func TestNewEmailNotifierFromEnv(t *testing.T) {
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	t.Setenv("SMTP_PORT", "")
+	t.Setenv("SMTP_USERNAME", "bot@example.com")
+	t.Setenv("SMTP_PASSWORD", "secret")
+	t.Setenv("SMTP_FROM", "")
+	t.Setenv("SMTP_TO", " alice@example.com, ,Bob <bob@example.com>")
+	t.Setenv("SMTP_STARTTLS", "")
+
+	e, err := newEmailNotifierFromEnv()
+	if err != nil {
+		t.Fatalf("newEmailNotifierFromEnv: %v", err)
+	}
+	if e.port != 587 || !e.startTLS || e.from != "bot@example.com" || len(e.to) != 2 {
+		t.Errorf("notifier = %+v", e)
+	}
+
+	t.Setenv("SMTP_PORT", strconv.Itoa(2525))
+	t.Setenv("SMTP_STARTTLS", "false")
+	if e, err := newEmailNotifierFromEnv(); err != nil || e.port != 2525 || e.startTLS {
+		t.Errorf("notifier = %+v, %v", e, err)
+	}
+
+	t.Setenv("SMTP_TO", "not an address")
+	if _, err := newEmailNotifierFromEnv(); err == nil {
+		t.Error("expected error for invalid recipient")
+	}
+	t.Setenv("SMTP_TO", "")
+	if _, err := newEmailNotifierFromEnv(); err == nil {
+		t.Error("expected error without recipients")
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// openMeteoGeocodingDefaultURL è l'endpoint di ricerca dell'API di geocoding di Open-Meteo
+const openMeteoGeocodingDefaultURL = "https://geocoding-api.open-meteo.com/v1/search"
+
+// maxSearchResults è il numero massimo di candidati restituiti da una ricerca
+const maxSearchResults = 10
+
+// errGeocodeUnsupported indica un'operazione non offerta dal geocoder
+var errGeocodeUnsupported = errors.New("operazione non supportata")
+
+// Geocoder converte nomi di luoghi in coordinate e viceversa
+type Geocoder interface {
+	Name() string
+	// Search restituisce i candidati per il nome indicato, dal più rilevante
+	Search(ctx context.Context, query string, limit int) ([]GeoLocation, error)
+	// Reverse restituisce la località più vicina alle coordinate
+	Reverse(ctx context.Context, lat, lon float64) (GeoLocation, error)
+}
+
+// fallbackGeocoder interroga i geocoder in ordine finché uno risponde
+type fallbackGeocoder struct {
+	geocoders []Geocoder
+}
+
+// Name restituisce i nomi dei geocoder della catena
+func (g *fallbackGeocoder) Name() string {
+	names := make([]string, len(g.geocoders))
+	for i, geocoder := range g.geocoders {
+		names[i] = geocoder.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// Search restituisce i candidati del primo geocoder disponibile
+func (g *fallbackGeocoder) Search(ctx context.Context, query string, limit int) ([]GeoLocation, error) {
+	var errs []error
+	for _, geocoder := range g.geocoders {
+		results, err := geocoder.Search(ctx, query, limit)
+		if err == nil {
+			return results, nil
+		}
+		if !errors.Is(err, errGeocodeUnsupported) {
+			log.Printf("⚠️ Geocoder %s non disponibile: %v", geocoder.Name(), err)
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", geocoder.Name(), err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Reverse restituisce la località del primo geocoder disponibile
+func (g *fallbackGeocoder) Reverse(ctx context.Context, lat, lon float64) (GeoLocation, error) {
+	var errs []error
+	for _, geocoder := range g.geocoders {
+		place, err := geocoder.Reverse(ctx, lat, lon)
+		if err == nil {
+			return place, nil
+		}
+		if !errors.Is(err, errGeocodeUnsupported) {
+			log.Printf("⚠️ Geocoder %s non disponibile: %v", geocoder.Name(), err)
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", geocoder.Name(), err))
+	}
+	return GeoLocation{}, errors.Join(errs...)
+}
+
+// newGeocoder costruisce il geocoder a partire da una lista separata da virgole
+func newGeocoder(names string) (Geocoder, error) {
+	var geocoders []Geocoder
+	for _, name := range strings.Split(names, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "openmeteo", "open-meteo":
+			geocoders = append(geocoders, &openMeteoGeocoder{baseURL: geocodingURL})
+		case "", "nominatim":
+			geocoders = append(geocoders, newNominatimGeocoder(nominatimURL, nominatimUserAgent, nominatimEmail))
+		case "geonames":
+			// Senza dump il resto della catena resta utilizzabile
+			g, err := newGeoNamesGeocoder(geoNamesFile, geoNamesCountryInfo, geoNamesMaxKm)
+			if err != nil {
+				log.Printf("⚠️ Geocoder geonames non disponibile: %v", err)
+				continue
+			}
+			log.Printf("🗺️ GeoNames caricato: %d località da %s", len(g.places), geoNamesFile)
+			geocoders = append(geocoders, g)
+		default:
+			return nil, fmt.Errorf("geocoder sconosciuto: %q", name)
+		}
+	}
+	switch len(geocoders) {
+	case 0:
+		return nil, fmt.Errorf("nessun geocoder disponibile in %q", names)
+	case 1:
+		return geocoders[0], nil
+	}
+	return &fallbackGeocoder{geocoders: geocoders}, nil
+}
+
+// getJSON esegue una GET con gli header indicati e decodifica la risposta JSON in out
+func getJSON(ctx context.Context, requestURL string, header http.Header, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// openMeteoGeocoder usa l'API di geocoding di Open-Meteo, che offre
+// solo la ricerca per nome ma restituisce anche il fuso orario
+type openMeteoGeocoder struct {
+	baseURL string
+}
+
+// Name restituisce il nome del geocoder
+func (g *openMeteoGeocoder) Name() string {
+	return "openmeteo"
+}
+
+// Search cerca le località per nome, ordinate per rilevanza e popolazione
+func (g *openMeteoGeocoder) Search(ctx context.Context, query string, limit int) ([]GeoLocation, error) {
+	baseURL := g.baseURL
+	if baseURL == "" {
+		baseURL = openMeteoGeocodingDefaultURL
+	}
+
+	params := url.Values{}
+	params.Set("name", query)
+	params.Set("count", strconv.Itoa(limit))
+	params.Set("language", "it")
+	params.Set("format", "json")
+
+	var data struct {
+		Results []struct {
+			Name      string  `json:"name"`
+			Latitude  float64 `json:"latitude"`
+			Longitude float64 `json:"longitude"`
+			Country   string  `json:"country"`
+			Admin1    string  `json:"admin1"`
+			Timezone  string  `json:"timezone"`
+		} `json:"results"`
+	}
+	if err := getJSON(ctx, baseURL+"?"+params.Encode(), nil, &data); err != nil {
+		return nil, err
+	}
+
+	results := make([]GeoLocation, 0, len(data.Results))
+	for _, r := range data.Results {
+		results = append(results, GeoLocation{
+			Lat:      r.Latitude,
+			Lon:      r.Longitude,
+			City:     r.Name,
+			Admin:    r.Admin1,
+			Country:  r.Country,
+			Timezone: r.Timezone,
+		})
+	}
+	return results, nil
+}
+
+// Reverse non è offerto dall'API di Open-Meteo
+func (g *openMeteoGeocoder) Reverse(ctx context.Context, lat, lon float64) (GeoLocation, error) {
+	return GeoLocation{}, errGeocodeUnsupported
+}
+
+// searchPlaces cerca le località per nome, usando la cache
+func searchPlaces(ctx context.Context, query string, limit int) ([]GeoLocation, error) {
+	key := fmt.Sprintf("%s|%d", strings.ToLower(query), limit)
+	return searchCache.get(ctx, key, func(ctx context.Context) ([]GeoLocation, error) {
+		return geocoder.Search(ctx, query, limit)
+	})
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// earthRadiusKm è il raggio medio terrestre
+const earthRadiusKm = 6371.0
+
+// geoNamesPlace è una località del dump GeoNames con la posizione
+// convertita in un punto della sfera unitaria
+type geoNamesPlace struct {
+	name     string
+	country  string
+	timezone string
+	lat, lon float64
+	point    [3]float64
+}
+
+// geoNamesGeocoder risolve le coordinate nella località abitata più vicina
+// usando un file GeoNames (cities500.txt, cities1000.txt, ...) caricato in
+// un k-d tree, senza dipendere da servizi esterni
+type geoNamesGeocoder struct {
+	places []geoNamesPlace // ordinate come k-d tree implicito
+	maxKm  float64
+}
+
+// newGeoNamesGeocoder carica il dump delle città e, se indicato, il file
+// countryInfo.txt per i nomi dei paesi (altrimenti resta il codice ISO)
+func newGeoNamesGeocoder(citiesPath, countryInfoPath string, maxKm float64) (*geoNamesGeocoder, error) {
+	countries := map[string]string{}
+	if countryInfoPath != "" {
+		err := readGeoNamesFile(countryInfoPath, func(fields []string) {
+			if len(fields) > 4 {
+				countries[fields[0]] = fields[4]
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var places []geoNamesPlace
+	err := readGeoNamesFile(citiesPath, func(fields []string) {
+		if len(fields) < 18 {
+			return
+		}
+		lat, errLat := strconv.ParseFloat(fields[4], 64)
+		lon, errLon := strconv.ParseFloat(fields[5], 64)
+		if errLat != nil || errLon != nil {
+			return
+		}
+		country := fields[8]
+		if name, ok := countries[country]; ok {
+			country = name
+		}
+		places = append(places, geoNamesPlace{
+			name:     fields[1],
+			country:  country,
+			timezone: fields[17],
+			lat:      lat,
+			lon:      lon,
+			point:    unitVector(lat, lon),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(places) == 0 {
+		return nil, fmt.Errorf("%s: nessuna località valida", citiesPath)
+	}
+
+	buildKDTree(places, 0)
+	return &geoNamesGeocoder{places: places, maxKm: maxKm}, nil
+}
+
+// readGeoNamesFile legge un file GeoNames separato da tabulazioni, saltando i commenti
+func readGeoNamesFile(path string, row func(fields []string)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		row(strings.Split(line, "\t"))
+	}
+	return scanner.Err()
+}
+
+// unitVector converte latitudine e longitudine in un punto della sfera unitaria,
+// dove la distanza euclidea cresce con quella lungo la superficie
+func unitVector(lat, lon float64) [3]float64 {
+	rad := math.Pi / 180
+	return [3]float64{
+		math.Cos(lat*rad) * math.Cos(lon*rad),
+		math.Cos(lat*rad) * math.Sin(lon*rad),
+		math.Sin(lat * rad),
+	}
+}
+
+// buildKDTree ordina le località come k-d tree implicito: la mediana di ogni
+// intervallo è il nodo, a sinistra e a destra i due sottoalberi
+func buildKDTree(places []geoNamesPlace, depth int) {
+	if len(places) <= 1 {
+		return
+	}
+	axis := depth % 3
+	sort.Slice(places, func(i, j int) bool {
+		return places[i].point[axis] < places[j].point[axis]
+	})
+	mid := len(places) / 2
+	buildKDTree(places[:mid], depth+1)
+	buildKDTree(places[mid+1:], depth+1)
+}
+
+// nearest restituisce la località più vicina al punto indicato
+func (g *geoNamesGeocoder) nearest(target [3]float64) (geoNamesPlace, float64) {
+	best := -1
+	bestDist := math.Inf(1)
+
+	var search func(lo, hi, depth int)
+	search = func(lo, hi, depth int) {
+		if lo >= hi {
+			return
+		}
+		mid := lo + (hi-lo)/2
+		place := &g.places[mid]
+
+		var dist float64
+		for i := range 3 {
+			d := place.point[i] - target[i]
+			dist += d * d
+		}
+		if dist < bestDist {
+			best, bestDist = mid, dist
+		}
+
+		axis := depth % 3
+		diff := target[axis] - place.point[axis]
+		near, far := [2]int{lo, mid}, [2]int{mid + 1, hi}
+		if diff > 0 {
+			near, far = far, near
+		}
+		search(near[0], near[1], depth+1)
+		if diff*diff < bestDist {
+			search(far[0], far[1], depth+1)
+		}
+	}
+	search(0, len(g.places), 0)
+
+	// Distanza della corda convertita in distanza lungo la superficie
+	chord := math.Sqrt(bestDist)
+	return g.places[best], 2 * earthRadiusKm * math.Asin(math.Min(1, chord/2))
+}
+
+// Name restituisce il nome del geocoder
+func (g *geoNamesGeocoder) Name() string {
+	return "geonames"
+}
+
+// Search non è offerto dal geocoder offline
+func (g *geoNamesGeocoder) Search(ctx context.Context, query string, limit int) ([]GeoLocation, error) {
+	return nil, errGeocodeUnsupported
+}
+
+// Reverse restituisce la località abitata più vicina, entro maxKm se impostato
+func (g *geoNamesGeocoder) Reverse(ctx context.Context, lat, lon float64) (GeoLocation, error) {
+	place, km := g.nearest(unitVector(lat, lon))
+	if g.maxKm > 0 && km > g.maxKm {
+		return GeoLocation{}, fmt.Errorf("nessuna località entro %g km (la più vicina è %s a %.0f km)", g.maxKm, place.name, km)
+	}
+	return GeoLocation{
+		Lat:      lat,
+		Lon:      lon,
+		City:     place.name,
+		Country:  place.country,
+		Timezone: place.timezone,
+	}, nil
+}
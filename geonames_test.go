@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Il dump in testdata contiene Roma, Milano e Londra; Napoli ha una riga
+// corta e Firenze una latitudine non valida, quindi vanno entrambe scartate
+const (
+	geoNamesTestCities      = "testdata/geonames-cities.txt"
+	geoNamesTestCountryInfo = "testdata/geonames-countryinfo.txt"
+)
+
+func TestNewGeoNamesGeocoder(t *testing.T) {
+	g, err := newGeoNamesGeocoder(geoNamesTestCities, geoNamesTestCountryInfo, 0)
+	if err != nil {
+		t.Fatalf("newGeoNamesGeocoder: %v", err)
+	}
+	if len(g.places) != 3 {
+		t.Errorf("places = %d, want 3", len(g.places))
+	}
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		want     GeoLocation
+	}{
+		{"Roma", 41.9, 12.5, GeoLocation{City: "Rome", Country: "Italy", Timezone: "Europe/Rome"}},
+		{"Monza", 45.58, 9.27, GeoLocation{City: "Milan", Country: "Italy", Timezone: "Europe/Rome"}},
+		// GB manca da countryInfo: resta il codice ISO
+		{"Londra", 51.5, -0.1, GeoLocation{City: "London", Country: "GB", Timezone: "Europe/London"}},
+		// Napoli è stata scartata: la più vicina è Roma
+		{"Napoli", 40.85, 14.27, GeoLocation{City: "Rome", Country: "Italy", Timezone: "Europe/Rome"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := g.Reverse(context.Background(), tt.lat, tt.lon)
+			if err != nil {
+				t.Fatalf("Reverse: %v", err)
+			}
+			tt.want.Lat, tt.want.Lon = tt.lat, tt.lon
+			if got != tt.want {
+				t.Errorf("Reverse = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewGeoNamesGeocoderWithoutCountryInfo(t *testing.T) {
+	g, err := newGeoNamesGeocoder(geoNamesTestCities, "", 0)
+	if err != nil {
+		t.Fatalf("newGeoNamesGeocoder: %v", err)
+	}
+	got, err := g.Reverse(context.Background(), 41.9, 12.5)
+	if err != nil {
+		t.Fatalf("Reverse: %v", err)
+	}
+	if got.Country != "IT" {
+		t.Errorf("Country = %q, want IT", got.Country)
+	}
+
+	if _, err := newGeoNamesGeocoder("testdata/geonames-missing.txt", "", 0); err == nil {
+		t.Error("newGeoNamesGeocoder: expected error for missing file")
+	}
+	if _, err := newGeoNamesGeocoder(geoNamesTestCountryInfo, "", 0); err == nil {
+		t.Error("newGeoNamesGeocoder: expected error without valid places")
+	}
+}
+
+func TestGeoNamesReverseMaxKm(t *testing.T) {
+	g, err := newGeoNamesGeocoder(geoNamesTestCities, geoNamesTestCountryInfo, 50)
+	if err != nil {
+		t.Fatalf("newGeoNamesGeocoder: %v", err)
+	}
+
+	// Frascati è a circa 20 km da Roma, Napoli a quasi 190
+	if got, err := g.Reverse(context.Background(), 41.81, 12.68); err != nil || got.City != "Rome" {
+		t.Errorf("Reverse(Frascati) = %+v, %v", got, err)
+	}
+	_, err = g.Reverse(context.Background(), 40.85, 14.27)
+	if err == nil || !strings.Contains(err.Error(), "Rome") {
+		t.Errorf("Reverse(Napoli) error = %v, want nearest Rome beyond 50 km", err)
+	}
+}
+
+func TestNewGeocoderGeoNamesFallback(t *testing.T) {
+	previousFile, previousInfo, previousKm := geoNamesFile, geoNamesCountryInfo, geoNamesMaxKm
+	previousURL, previousDisk, previousLimiter := nominatimURL, nominatimDiskCache, nominatimLimiter
+	t.Cleanup(func() {
+		geoNamesFile, geoNamesCountryInfo, geoNamesMaxKm = previousFile, previousInfo, previousKm
+		nominatimURL, nominatimDiskCache, nominatimLimiter = previousURL, previousDisk, previousLimiter
+	})
+
+	var requests int
+	srv := newFixtureServer(t, `{"address":{"town":"Ercolano","state":"Campania","country":"Italia"}}`, func(r *http.Request) {
+		requests++
+		if r.URL.Path != "/reverse" {
+			t.Errorf("request = %s", r.URL)
+		}
+	})
+	nominatimURL = srv.URL
+	nominatimDiskCache = nil
+	// Un limiter nuovo evita di attendere le richieste degli altri test
+	nominatimLimiter = &rateLimiter{interval: time.Second, maxWait: 3 * time.Second}
+	geoNamesFile, geoNamesCountryInfo, geoNamesMaxKm = geoNamesTestCities, geoNamesTestCountryInfo, 50
+
+	g, err := newGeocoder("geonames,nominatim")
+	if err != nil {
+		t.Fatalf("newGeocoder: %v", err)
+	}
+	if g.Name() != "geonames,nominatim" {
+		t.Errorf("Name = %q", g.Name())
+	}
+
+	// Entro maxKm risponde GeoNames senza interrogare Nominatim
+	place, err := g.Reverse(context.Background(), 41.9, 12.5)
+	if err != nil || place.City != "Rome" || requests != 0 {
+		t.Errorf("Reverse(Roma) = %+v, %v with %d requests", place, err, requests)
+	}
+	// Oltre maxKm si passa a Nominatim
+	place, err = g.Reverse(context.Background(), 40.81, 14.35)
+	if err != nil || place.City != "Ercolano" || requests != 1 {
+		t.Errorf("Reverse(Ercolano) = %+v, %v with %d requests", place, err, requests)
+	}
+
+	// Senza dump la catena resta utilizzabile con il solo Nominatim
+	geoNamesFile = "testdata/geonames-missing.txt"
+	g, err = newGeocoder("geonames,nominatim")
+	if err != nil {
+		t.Fatalf("newGeocoder senza dump: %v", err)
+	}
+	if g.Name() != "nominatim" {
+		t.Errorf("Name senza dump = %q, want nominatim", g.Name())
+	}
+}
@@ -5,4 +5,7 @@ go 1.25.6
 require (
 	github.com/hectormalot/omgo v0.2.1
 	github.com/joho/godotenv v1.5.1
+	github.com/oschwald/maxminddb-golang/v2 v2.1.1
 )
+
+require golang.org/x/sys v0.38.0 // indirect
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// gotifyNotifier invia le notifiche a un server Gotify con il token di un'applicazione
+type gotifyNotifier struct {
+	serverURL string
+	token     string
+	priority  int // da 0 a 10; da 8 in su Gotify mostra una notifica a tutto schermo
+}
+
+// newGotifyNotifierFromEnv crea il canale Gotify dalle variabili GOTIFY_*
+func newGotifyNotifierFromEnv() (*gotifyNotifier, error) {
+	g := &gotifyNotifier{
+		serverURL: strings.TrimSuffix(os.Getenv("GOTIFY_URL"), "/"),
+		token:     os.Getenv("GOTIFY_TOKEN"),
+		priority:  min(envInt("GOTIFY_PRIORITY", 5), 10),
+	}
+	if g.serverURL == "" || g.token == "" {
+		return nil, fmt.Errorf("GOTIFY_URL e GOTIFY_TOKEN sono obbligatori")
+	}
+	return g, nil
+}
+
+// Name restituisce il nome del canale
+func (g *gotifyNotifier) Name() string {
+	return "gotify"
+}
+
+// Notify invia il riepilogo meteo in Markdown, con link alla pagina al tocco
+func (g *gotifyNotifier) Notify(ctx context.Context, n Notification) error {
+	summary, data := n.Summary, n.Data
+
+	var message strings.Builder
+	message.WriteString(summary.Subtitle)
+	for _, section := range summary.Sections {
+		fmt.Fprintf(&message, "\n\n**%s**", section.Title)
+		for _, line := range section.Lines {
+			// Due spazi finali per andare a capo in Markdown
+			message.WriteString("  \n" + line)
+		}
+	}
+
+	extras := map[string]interface{}{
+		"client::display": map[string]string{"contentType": "text/markdown"},
+	}
+	if link := homePageURL(data); link != "" {
+		extras["client::notification"] = map[string]interface{}{
+			"click": map[string]string{"url": link},
+		}
+	}
+
+	payload := map[string]interface{}{
+		"title":    summary.Title,
+		"message":  message.String(),
+		"priority": g.priority,
+		"extras":   extras,
+	}
+
+	header := http.Header{}
+	header.Set("X-Gotify-Key", g.token)
+	return postJSON(ctx, g.serverURL+"/message", header, payload)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// gotifyPayload è il corpo inviato all'endpoint /message di Gotify
+type gotifyPayload struct {
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+	Extras   struct {
+		Display struct {
+			ContentType string `json:"contentType"`
+		} `json:"client::display"`
+		Notification *struct {
+			Click struct {
+				URL string `json:"url"`
+			} `json:"click"`
+		} `json:"client::notification"`
+	} `json:"extras"`
+}
+
+func TestGotifyNotify(t *testing.T) {
+	previous := publicURL
+	t.Cleanup(func() { publicURL = previous })
+
+	for name, base := range map[string]string{"con link": "https://meteo.example.com", "senza link": ""} {
+		t.Run(name, func(t *testing.T) {
+			publicURL = base
+
+			var payload gotifyPayload
+			srv := newFixtureServer(t, "", func(r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/message" {
+					t.Errorf("request = %s %s", r.Method, r.URL.Path)
+				}
+				if got := r.Header.Get("X-Gotify-Key"); got != "app-token" {
+					t.Errorf("X-Gotify-Key = %q", got)
+				}
+				if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+					t.Errorf("decode: %v", err)
+				}
+			})
+			g := &gotifyNotifier{serverURL: srv.URL, token: "app-token", priority: 8}
+
+			if err := g.Notify(context.Background(), testNotification()); err != nil {
+				t.Fatalf("Notify: %v", err)
+			}
+
+			if payload.Title != "🌤️ Meteo Roma" || payload.Priority != 8 {
+				t.Errorf("payload = %+v", payload)
+			}
+			if payload.Extras.Display.ContentType != "text/markdown" {
+				t.Errorf("contentType = %q", payload.Extras.Display.ContentType)
+			}
+			// Markdown: titoli in grassetto e due spazi prima di ogni a capo
+			if !strings.Contains(payload.Message, "**Condizioni Attuali**  \n🌦️ Pioggia leggera  \n💧 Umidità: 55%") {
+				t.Errorf("message = %q", payload.Message)
+			}
+
+			if base == "" {
+				if payload.Extras.Notification != nil {
+					t.Errorf("client::notification = %+v, want none", payload.Extras.Notification)
+				}
+				return
+			}
+			want := base + "/?lat=41.9028&lon=12.4964"
+			if payload.Extras.Notification == nil || payload.Extras.Notification.Click.URL != want {
+				t.Errorf("client::notification = %+v, want click %s", payload.Extras.Notification, want)
+			}
+		})
+	}
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -28,6 +33,8 @@ func toggleNotificationsHandler(w http.ResponseWriter, r *http.Request) {
 	newState := notificationsEnabled
 	notificationsMutex.RUnlock()
 
+	saveSettings()
+
 	w.Header().Set(contentTypeHeader, contentTypeJSON)
 	_ = json.NewEncoder(w).Encode(map[string]bool{"enabled": newState})
 }
@@ -43,6 +50,7 @@ func getConfigHandler(w http.ResponseWriter, r *http.Request) {
 	interval := int(notificationInterval / time.Minute)
 	start := notificationStartHour
 	end := notificationEndHour
+	u := units
 	configMutex.RUnlock()
 
 	notificationsMutex.RLock()
@@ -55,6 +63,7 @@ func getConfigHandler(w http.ResponseWriter, r *http.Request) {
 		StartHour:       start,
 		EndHour:         end,
 		NotificationsOn: on,
+		Units:           u,
 	})
 }
 
@@ -85,6 +94,11 @@ func updateConfigHandler(w http.ResponseWriter, r *http.Request) {
 	notificationInterval = time.Duration(req.IntervalMinutes) * time.Minute
 	notificationStartHour = req.StartHour
 	notificationEndHour = req.EndHour
+	// Le unità sono opzionali: se assenti restano quelle correnti
+	if req.Units != nil {
+		units = req.Units.normalize()
+	}
+	u := units
 	configMutex.Unlock()
 
 	notificationsMutex.Lock()
@@ -96,12 +110,15 @@ func updateConfigHandler(w http.ResponseWriter, r *http.Request) {
 	on := notificationsEnabled
 	notificationsMutex.Unlock()
 
+	saveSettings()
+
 	w.Header().Set(contentTypeHeader, contentTypeJSON)
 	_ = json.NewEncoder(w).Encode(ConfigResponse{
 		IntervalMinutes: req.IntervalMinutes,
 		StartHour:       req.StartHour,
 		EndHour:         req.EndHour,
 		NotificationsOn: on,
+		Units:           u,
 	})
 }
 
@@ -124,17 +141,30 @@ func setLocationHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	locationMutex.Lock()
-	customLat = req.Lat
-	customLon = req.Lon
-	useCustom = true
-	locationMutex.Unlock()
+	// Senza nome la posizione sostituisce quella personalizzata precedente
+	saved := SavedLocation{ID: customLocationID, Name: customLocationLabel, Lat: req.Lat, Lon: req.Lon}
+	var err error
+	if req.Name != "" {
+		saved.Name = req.Name
+		saved, err = addLocation(saved)
+	} else {
+		saved, err = putLocation(saved)
+	}
+	if err == nil {
+		err = selectLocation(saved.ID, false)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	log.Printf("📍 Posizione personalizzata impostata: %.4f, %.4f", req.Lat, req.Lon)
+	log.Printf("📍 Posizione personalizzata impostata: %s (%.4f, %.4f)", saved.Name, req.Lat, req.Lon)
+	saveSettings()
 
 	w.Header().Set(contentTypeHeader, contentTypeJSON)
 	_ = json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
+		"id":      saved.ID,
 		"lat":     req.Lat,
 		"lon":     req.Lon,
 	})
@@ -147,12 +177,416 @@ func resetLocationHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	locationMutex.Lock()
-	useCustom = false
-	locationMutex.Unlock()
+	_ = selectLocation("", false)
 
 	log.Println("📍 Ripristinata geolocalizzazione automatica")
+	saveSettings()
 
 	w.Header().Set(contentTypeHeader, contentTypeJSON)
 	_ = json.NewEncoder(w).Encode(map[string]bool{"success": true})
 }
+
+// browserLocationHandler riceve la posizione rilevata dal browser (POST): la
+// salva come posizione delle notifiche o la mostra temporaneamente al solo
+// visitatore. DELETE termina la visualizzazione temporanea.
+func browserLocationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete {
+		clearBrowserPositionCookie(w)
+		w.Header().Set(contentTypeHeader, contentTypeJSON)
+		_ = json.NewEncoder(w).Encode(map[string]bool{"success": true})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, methodNotAllowedMsg, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req BrowserLocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	position := browserPosition{Lat: req.Lat, Lon: req.Lon, Accuracy: req.Accuracy}
+	if err := validateBrowserPosition(position); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.Save {
+		saved, err := putLocation(SavedLocation{
+			ID:       browserLocationID,
+			Name:     browserLocationLabel,
+			Lat:      req.Lat,
+			Lon:      req.Lon,
+			Accuracy: req.Accuracy,
+		})
+		if err == nil {
+			err = selectLocation(saved.ID, true)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("📱 Posizione del browser salvata per le notifiche: %.4f, %.4f (±%.0f m)", req.Lat, req.Lon, req.Accuracy)
+		saveSettings()
+	} else {
+		setBrowserPositionCookie(w, position)
+		log.Printf("📱 Visualizzazione temporanea dal browser: %.4f, %.4f (±%.0f m)", req.Lat, req.Lon, req.Accuracy)
+	}
+
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"saved":    req.Save,
+		"lat":      req.Lat,
+		"lon":      req.Lon,
+		"accuracy": req.Accuracy,
+	})
+}
+
+// locationsHandler elenca (GET) o aggiunge (POST) le posizioni salvate
+func locationsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req SavedLocation
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		saved, err := addLocation(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("📍 Posizione salvata: %s (%.4f, %.4f)", saved.Name, saved.Lat, saved.Lon)
+		saveSettings()
+	default:
+		http.Error(w, methodNotAllowedMsg, http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	_ = json.NewEncoder(w).Encode(listLocations())
+}
+
+// locationHandler modifica (PUT) o elimina (DELETE) una posizione salvata
+func locationHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var err error
+	switch r.Method {
+	case http.MethodPut:
+		var req SavedLocation
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		req.ID = id
+		_, err = updateLocation(req)
+	case http.MethodDelete:
+		err = deleteLocation(id)
+	default:
+		http.Error(w, methodNotAllowedMsg, http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err == errLocationNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	saveSettings()
+
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	_ = json.NewEncoder(w).Encode(listLocations())
+}
+
+// activateLocationHandler sceglie la posizione salvata mostrata nella pagina
+func activateLocationHandler(w http.ResponseWriter, r *http.Request) {
+	selectLocationHandler(w, r, false)
+}
+
+// notificationLocationHandler sceglie la posizione salvata usata dalle notifiche
+func notificationLocationHandler(w http.ResponseWriter, r *http.Request) {
+	selectLocationHandler(w, r, true)
+}
+
+// selectLocationHandler gestisce la selezione di una posizione salvata
+func selectLocationHandler(w http.ResponseWriter, r *http.Request, notify bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, methodNotAllowedMsg, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SelectLocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if err := selectLocation(req.ID, notify); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if notify {
+		log.Printf("🔔 Posizione notifiche: %q", req.ID)
+	} else {
+		log.Printf("📍 Posizione attiva: %q", req.ID)
+	}
+	saveSettings()
+
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	_ = json.NewEncoder(w).Encode(listLocations())
+}
+
+// searchHandler cerca le località per nome e restituisce i candidati
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, methodNotAllowedMsg, http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		http.Error(w, "Parametro q obbligatorio", http.StatusBadRequest)
+		return
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > maxSearchResults {
+		limit = 5
+	}
+
+	results, err := searchPlaces(r.Context(), query, limit)
+	if err != nil {
+		http.Error(w, "Errore ricerca: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	_ = json.NewEncoder(w).Encode(SearchResponse{Query: query, Results: results})
+}
+
+// hourlyHandler restituisce la timeline oraria delle prossime 48 ore
+func hourlyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, methodNotAllowedMsg, http.StatusMethodNotAllowed)
+		return
+	}
+
+	v, err := newVisitor(r)
+	if err != nil {
+		writeVisitorError(w, err)
+		return
+	}
+
+	data, err := getWeather(r.Context(), v)
+	if err != nil {
+		http.Error(w, "Errore meteo: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	_ = json.NewEncoder(w).Encode(HourlyResponse{
+		City:    data.City,
+		Country: data.Country,
+		Lat:     data.Lat,
+		Lon:     data.Lon,
+		Units:   data.Units,
+		Hours:   data.Hours,
+	})
+}
+
+// airQualityHandler restituisce qualità dell'aria e pollini della posizione corrente
+func airQualityHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, methodNotAllowedMsg, http.StatusMethodNotAllowed)
+		return
+	}
+
+	v, err := newVisitor(r)
+	if err != nil {
+		writeVisitorError(w, err)
+		return
+	}
+
+	// Serve solo la posizione: la previsione meteo non va scaricata
+	location := resolveLocation(r.Context(), v)
+	airQuality, err := getAirQuality(location.Lat, location.Lon)
+	if err != nil {
+		http.Error(w, "Qualità dell'aria non disponibile: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	_ = json.NewEncoder(w).Encode(AirQualityResponse{
+		City:       location.City,
+		Country:    location.Country,
+		Lat:        location.Lat,
+		Lon:        location.Lon,
+		AirQuality: airQuality,
+	})
+}
+
+// historyHandler restituisce il meteo storico della posizione corrente in JSON o CSV
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, methodNotAllowedMsg, http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	start := query.Get("start")
+	end := query.Get("end")
+	if end == "" {
+		end = start
+	}
+
+	v, err := newVisitor(r)
+	if err != nil {
+		writeVisitorError(w, err)
+		return
+	}
+	location := resolveLocation(r.Context(), v)
+
+	configMutex.RLock()
+	u := units
+	configMutex.RUnlock()
+
+	history, err := getHistory(location, start, end, u)
+	if errors.Is(err, errInvalidHistoryRange) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Errore storico: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	if query.Get("format") == "csv" {
+		series := query.Get("series")
+		if series != "hourly" {
+			series = "daily"
+		}
+		w.Header().Set(contentTypeHeader, "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition",
+			fmt.Sprintf("attachment; filename=\"meteo-%s-%s-%s.csv\"", series, start, end))
+		if err := writeHistoryCSV(csv.NewWriter(w), history, series); err != nil {
+			log.Printf("❌ Errore CSV storico: %v", err)
+		}
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	_ = json.NewEncoder(w).Encode(history)
+}
+
+// cacheStatsHandler restituisce le statistiche delle cache
+func cacheStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, methodNotAllowedMsg, http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	_ = json.NewEncoder(w).Encode(map[string]CacheStats{
+		"forecast":    forecastCache.stats(),
+		"geocode":     geocodeCache.stats(),
+		"ip":          ipCache.stats(),
+		"search":      searchCache.stats(),
+		"air_quality": airQualityCache.stats(),
+		"nowcast":     nowcastCache.stats(),
+	})
+}
+
+// alertsHandler elenca (GET) o aggiunge (POST) le regole di avviso
+func alertsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req alertRulePayload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		rule, err := addAlertRule(req.rule(currentUnits()))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("⚠️ Regola di avviso aggiunta: %s", rule.Name)
+		saveSettings()
+	default:
+		http.Error(w, methodNotAllowedMsg, http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	_ = json.NewEncoder(w).Encode(alertRulePayloads(currentUnits()))
+}
+
+// alertHandler modifica (PUT) o elimina (DELETE) una regola di avviso
+func alertHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var err error
+	switch r.Method {
+	case http.MethodPut:
+		var req alertRulePayload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		rule := req.rule(currentUnits())
+		rule.ID = id
+		_, err = updateAlertRule(rule)
+	case http.MethodDelete:
+		err = deleteAlertRule(id)
+	default:
+		http.Error(w, methodNotAllowedMsg, http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err == errAlertRuleNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	saveSettings()
+
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	_ = json.NewEncoder(w).Encode(alertRulePayloads(currentUnits()))
+}
+
+// alertsCheckHandler valuta ora tutte le regole sulla posizione delle notifiche,
+// ignorando stato e cooldown, senza inviare nulla
+func alertsCheckHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, methodNotAllowedMsg, http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := getNotificationWeather()
+	if err != nil {
+		http.Error(w, "Errore meteo: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	events := []AlertEvent{}
+	for _, rule := range listAlertRules() {
+		if event, ok := rule.evaluate(data); ok {
+			events = append(events, event)
+		}
+	}
+
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	_ = json.NewEncoder(w).Encode(events)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hectormalot/omgo"
+)
+
+// maxHistoryDays è l'intervallo massimo interrogabile in una richiesta
+const maxHistoryDays = 366
+
+// dateLayout è il formato delle date accettate dall'archivio
+const dateLayout = "2006-01-02"
+
+// errInvalidHistoryRange indica date mancanti o fuori dai limiti dell'archivio
+var errInvalidHistoryRange = errors.New("periodo non valido")
+
+// validateHistoryRange controlla le date richieste rispetto al giorno now
+func validateHistoryRange(startDate, endDate string, now time.Time) error {
+	start, err := time.Parse(dateLayout, startDate)
+	if err != nil {
+		return fmt.Errorf("%w: data di inizio %q", errInvalidHistoryRange, startDate)
+	}
+	end, err := time.Parse(dateLayout, endDate)
+	if err != nil {
+		return fmt.Errorf("%w: data di fine %q", errInvalidHistoryRange, endDate)
+	}
+	if end.Before(start) {
+		return fmt.Errorf("%w: la data di fine precede quella di inizio", errInvalidHistoryRange)
+	}
+	if end.Sub(start) >= maxHistoryDays*24*time.Hour {
+		return fmt.Errorf("%w: intervallo massimo %d giorni", errInvalidHistoryRange, maxHistoryDays)
+	}
+	if !end.Before(now.Truncate(24 * time.Hour)) {
+		return fmt.Errorf("%w: l'archivio copre solo date passate", errInvalidHistoryRange)
+	}
+	return nil
+}
+
+// getHistory recupera dall'archivio Open-Meteo i dati del periodo indicato;
+// le date non valide restituiscono errInvalidHistoryRange
+func getHistory(location GeoLocation, startDate, endDate string, u Units) (*HistoryResponse, error) {
+	if err := validateHistoryRange(startDate, endDate, time.Now()); err != nil {
+		return nil, err
+	}
+
+	req, err := omgo.NewHistoricalRequest(location.Lat, location.Lon, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	req.WithHourly(
+		omgo.HourlyTemperature2m,
+		omgo.HourlyApparentTemperature,
+		omgo.HourlyWeatherCode,
+		omgo.HourlyPrecipitation,
+		omgo.HourlyWindSpeed10m,
+		omgo.HourlyRelativeHumidity2m,
+	).WithDaily(
+		omgo.DailyTemperature2mMax,
+		omgo.DailyTemperature2mMin,
+		omgo.DailyWeatherCode,
+		omgo.DailyPrecipitationSum,
+		omgo.DailyWindSpeed10mMax,
+		omgo.DailySunrise,
+		omgo.DailySunset,
+		omgo.DailyDaylightDuration,
+	).WithTimezone(timezoneOrAuto(location.Timezone)).
+		WithTemperatureUnit(u.omgoTemperature()).
+		WithPrecipitationUnit(u.omgoPrecipitation()).
+		WithWindSpeedUnit(u.omgoWind())
+
+	var opts []omgo.Option
+	if archiveURL != "" {
+		opts = append(opts, omgo.WithHistoricalURL(archiveURL))
+	}
+	weather, err := omgo.NewClient(opts...).Historical(context.Background(), req)
+	if err != nil {
+		return nil, err
+	}
+	if weather.Hourly == nil || weather.Daily == nil {
+		return nil, fmt.Errorf("risposta archivio incompleta")
+	}
+
+	forecast := &Forecast{
+		Provider: "openmeteo-archive",
+		Timezone: weather.Timezone,
+		Hourly:   omgoHourly(weather.Hourly, 1),
+		Daily:    omgoDaily(weather.Daily),
+	}
+	if u.Wind == windBeaufort {
+		u.convertWind(forecast)
+	}
+
+	history := &HistoryResponse{
+		City:      location.City,
+		Country:   location.Country,
+		Lat:       location.Lat,
+		Lon:       location.Lon,
+		Timezone:  forecast.Timezone,
+		StartDate: startDate,
+		EndDate:   endDate,
+		Units:     u,
+	}
+	for _, d := range forecast.Daily {
+		history.Daily = append(history.Daily, toDayForecast(d, d.Date.Format("02/01/2006")))
+	}
+	for _, h := range forecast.Hourly {
+		history.Hourly = append(history.Hourly, toHourForecast(h))
+	}
+
+	return history, nil
+}
+
+// writeHistoryCSV scrive la serie giornaliera o oraria in formato CSV
+func writeHistoryCSV(w *csv.Writer, history *HistoryResponse, series string) error {
+	f := func(value float64) string {
+		return strconv.FormatFloat(value, 'f', 1, 64)
+	}
+
+	if series == "hourly" {
+		if err := w.Write([]string{"time", "temperature", "apparent_temperature", "weather_code",
+			"precipitation", "wind_speed", "humidity"}); err != nil {
+			return err
+		}
+		for _, h := range history.Hourly {
+			if err := w.Write([]string{h.Time.Format("2006-01-02T15:04"), f(h.Temperature), f(h.ApparentTemperature),
+				strconv.Itoa(h.WeatherCode), f(h.Precipitation), f(h.WindSpeed), f(h.Humidity)}); err != nil {
+				return err
+			}
+		}
+	} else {
+		if err := w.Write([]string{"date", "min", "max", "weather_code", "precipitation_sum",
+			"wind_max", "sunrise", "sunset"}); err != nil {
+			return err
+		}
+		for _, d := range history.Daily {
+			if err := w.Write([]string{d.Date.Format(dateLayout), f(d.Min), f(d.Max), strconv.Itoa(d.WeatherCode),
+				f(d.PrecipitationSum), f(d.WindMax), d.Sunrise.Format("15:04"), d.Sunset.Format("15:04")}); err != nil {
+				return err
+			}
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestValidateHistoryRange(t *testing.T) {
+	now := time.Date(2026, 7, 15, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		start, end string
+		wantErr    bool
+	}{
+		{"singolo giorno", "2026-07-01", "2026-07-01", false},
+		{"ieri", "2026-07-10", "2026-07-14", false},
+		{"un anno", "2025-07-14", "2026-07-14", false},
+		{"inizio mancante", "", "2026-07-01", true},
+		{"inizio non valido", "01/07/2026", "2026-07-01", true},
+		{"fine non valida", "2026-07-01", "2026-13-01", true},
+		{"fine prima dell'inizio", "2026-07-02", "2026-07-01", true},
+		{"oltre un anno", "2025-07-13", "2026-07-14", true},
+		{"oggi", "2026-07-14", "2026-07-15", true},
+		{"futuro", "2026-08-01", "2026-08-02", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateHistoryRange(tt.start, tt.end, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateHistoryRange(%q, %q) = %v, wantErr %t", tt.start, tt.end, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, errInvalidHistoryRange) {
+				t.Errorf("error %v is not errInvalidHistoryRange", err)
+			}
+		})
+	}
+}
+
+func TestGetHistoryUpstreamError(t *testing.T) {
+	srv := newStatusServer(t, http.StatusInternalServerError)
+	previous := archiveURL
+	archiveURL = srv.URL
+	t.Cleanup(func() { archiveURL = previous })
+
+	// Un errore dell'archivio non deve essere scambiato per date non valide
+	_, err := getHistory(GeoLocation{Lat: 41.9, Lon: 12.5}, "2024-01-01", "2024-01-02", Units{}.normalize())
+	if err == nil {
+		t.Fatal("getHistory: expected error")
+	}
+	if errors.Is(err, errInvalidHistoryRange) {
+		t.Errorf("upstream error %v reported as invalid range", err)
+	}
+}
+
+func TestWriteHistoryCSV(t *testing.T) {
+	rome, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		t.Skip(err)
+	}
+	history := &HistoryResponse{
+		Daily: []DayForecast{{
+			Date:             time.Date(2024, 1, 1, 0, 0, 0, 0, rome),
+			Min:              2.04,
+			Max:              11.96,
+			WeatherCode:      61,
+			PrecipitationSum: 3.25,
+			WindMax:          18,
+			Sunrise:          time.Date(2024, 1, 1, 7, 37, 0, 0, rome),
+			Sunset:           time.Date(2024, 1, 1, 16, 49, 0, 0, rome),
+		}},
+		Hourly: []HourForecast{{
+			Time:                time.Date(2024, 1, 1, 13, 0, 0, 0, rome),
+			Temperature:         10.5,
+			ApparentTemperature: 8.25,
+			WeatherCode:         3,
+			Precipitation:       0,
+			WindSpeed:           12.34,
+			Humidity:            81,
+		}},
+	}
+
+	tests := []struct {
+		series string
+		want   string
+	}{
+		{"daily", "date,min,max,weather_code,precipitation_sum,wind_max,sunrise,sunset\n" +
+			"2024-01-01,2.0,12.0,61,3.2,18.0,07:37,16:49\n"},
+		{"", "date,min,max,weather_code,precipitation_sum,wind_max,sunrise,sunset\n" +
+			"2024-01-01,2.0,12.0,61,3.2,18.0,07:37,16:49\n"},
+		{"hourly", "time,temperature,apparent_temperature,weather_code,precipitation,wind_speed,humidity\n" +
+			"2024-01-01T13:00,10.5,8.2,3,0.0,12.3,81.0\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := writeHistoryCSV(csv.NewWriter(&buf), history, tt.series); err != nil {
+			t.Fatalf("writeHistoryCSV(%q): %v", tt.series, err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("writeHistoryCSV(%q) =\n%s\nwant\n%s", tt.series, got, tt.want)
+		}
+	}
+}
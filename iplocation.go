@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// serverLocationKey è la chiave in cache della posizione del server
+const serverLocationKey = "auto"
+
+// ipAPIDefaultURL è l'endpoint di ip-api, a cui si aggiunge l'indirizzo
+const ipAPIDefaultURL = "http://ip-api.com/json/"
+
+// ipAPITimeout limita l'attesa di ip-api, che blocca la risposta al visitatore
+const ipAPITimeout = 5 * time.Second
+
+// parseTrustedProxies legge una lista di IP o CIDR separati da virgole
+func parseTrustedProxies(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if strings.Contains(item, "/") {
+			prefix, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, fmt.Errorf("proxy fidato non valido: %q", item)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, fmt.Errorf("proxy fidato non valido: %q", item)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// isTrustedProxy indica se l'indirizzo appartiene a un proxy fidato
+func isTrustedProxy(addr netip.Addr) bool {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP ricava l'indirizzo del visitatore. X-Forwarded-For è considerato
+// solo se la richiesta arriva da un proxy fidato, e viene letto da destra
+// saltando i proxy fidati: il primo indirizzo non fidato è il client.
+func clientIP(r *http.Request) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	addr = addr.Unmap()
+
+	if !isTrustedProxy(addr) {
+		return addr
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+		if !isTrustedProxy(addr) {
+			break
+		}
+	}
+	return addr
+}
+
+// isPublicIP indica se l'indirizzo è geolocalizzabile
+func isPublicIP(addr netip.Addr) bool {
+	return addr.IsValid() && !addr.IsLoopback() && !addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() && !addr.IsUnspecified()
+}
+
+// lookupClientLocation geolocalizza l'indirizzo del visitatore; per indirizzi
+// privati o non validi (es. in rete locale) usa la posizione del server
+func lookupClientLocation(ctx context.Context, addr netip.Addr) (GeoLocation, error) {
+	if !isPublicIP(addr) {
+		return ipCache.get(ctx, serverLocationKey, func(ctx context.Context) (GeoLocation, error) {
+			return lookupIPLocation(ctx, "")
+		})
+	}
+
+	ip := addr.String()
+	return ipCache.get(ctx, ip, func(ctx context.Context) (GeoLocation, error) {
+		if geoIPDB != nil {
+			location, err := lookupGeoIPDB(addr)
+			if err == nil {
+				return location, nil
+			}
+			return location, fmt.Errorf("database GeoIP: %w", err)
+		}
+		return lookupIPLocation(ctx, ip)
+	})
+}
+
+// geoIPNames sono i nomi localizzati di un record GeoIP
+type geoIPNames struct {
+	Names map[string]string `maxminddb:"names"`
+}
+
+// localized restituisce il nome in italiano, o in inglese come ripiego
+func (n geoIPNames) localized() string {
+	for _, lang := range []string{"it", "en"} {
+		if name := n.Names[lang]; name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// geoIPRecord è la parte di un record GeoLite2-City/DB-IP che ci interessa
+type geoIPRecord struct {
+	City     geoIPNames `maxminddb:"city"`
+	Country  geoIPNames `maxminddb:"country"`
+	Location struct {
+		Latitude  *float64 `maxminddb:"latitude"`
+		Longitude *float64 `maxminddb:"longitude"`
+		TimeZone  string   `maxminddb:"time_zone"`
+	} `maxminddb:"location"`
+}
+
+// lookupGeoIPDB cerca l'indirizzo nel database MaxMind/DB-IP locale
+func lookupGeoIPDB(addr netip.Addr) (GeoLocation, error) {
+	var record geoIPRecord
+	if err := geoIPDB.Lookup(addr).Decode(&record); err != nil {
+		return GeoLocation{}, err
+	}
+	if record.Location.Latitude == nil || record.Location.Longitude == nil {
+		return GeoLocation{}, fmt.Errorf("%s non presente", addr)
+	}
+
+	return GeoLocation{
+		Lat:      *record.Location.Latitude,
+		Lon:      *record.Location.Longitude,
+		City:     record.City.localized(),
+		Country:  record.Country.localized(),
+		Timezone: record.Location.TimeZone,
+	}, nil
+}
+
+// lookupIPLocation usa ip-api per geolocalizzare l'indirizzo indicato,
+// o quello del server se vuoto
+func lookupIPLocation(ctx context.Context, ip string) (GeoLocation, error) {
+	baseURL := ipAPIURL
+	if baseURL == "" {
+		baseURL = ipAPIDefaultURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+ip, nil)
+	if err != nil {
+		return GeoLocation{}, err
+	}
+
+	client := &http.Client{Timeout: ipAPITimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return GeoLocation{}, err
+	}
+	defer resp.Body.Close()
+
+	var data struct {
+		GeoLocation
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return GeoLocation{}, err
+	}
+	if data.Status == "fail" {
+		return GeoLocation{}, fmt.Errorf("ip-api: %s", data.Message)
+	}
+	return data.GeoLocation, nil
+}
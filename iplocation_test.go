@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+// I database in testdata sono generati con mmdbwriter e contengono:
+// 81.2.69.0/24 Londra, 2a02:ff0::/32 Roma (nomi solo in inglese per la
+// città) e 89.160.20.112/28 con il solo paese, senza coordinate.
+func TestLookupGeoIPDB(t *testing.T) {
+	previous := geoIPDB
+	t.Cleanup(func() { geoIPDB = previous })
+
+	for _, size := range []int{24, 28, 32} {
+		t.Run(fmt.Sprintf("record %d bit", size), func(t *testing.T) {
+			db, err := maxminddb.Open(fmt.Sprintf("testdata/geoip-test-%d.mmdb", size))
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			t.Cleanup(func() { db.Close() })
+			if got := db.Metadata.RecordSize; got != uint(size) {
+				t.Fatalf("RecordSize = %d, want %d", got, size)
+			}
+			geoIPDB = db
+
+			tests := []struct {
+				ip      string
+				want    GeoLocation
+				wantErr bool
+			}{
+				{ip: "81.2.69.142", want: GeoLocation{Lat: 51.5142, Lon: -0.0931,
+					City: "Londra", Country: "Regno Unito", Timezone: "Europe/London"}},
+				{ip: "81.2.69.0", want: GeoLocation{Lat: 51.5142, Lon: -0.0931,
+					City: "Londra", Country: "Regno Unito", Timezone: "Europe/London"}},
+				{ip: "2a02:ff0:1234::1", want: GeoLocation{Lat: 41.8919, Lon: 12.5113,
+					City: "Rome", Country: "Italia", Timezone: "Europe/Rome"}},
+				{ip: "81.2.70.1", wantErr: true},
+				{ip: "8.8.8.8", wantErr: true},
+				{ip: "2a03::1", wantErr: true},
+				{ip: "89.160.20.115", wantErr: true},
+			}
+			for _, tt := range tests {
+				got, err := lookupGeoIPDB(netip.MustParseAddr(tt.ip))
+				if (err != nil) != tt.wantErr {
+					t.Errorf("lookupGeoIPDB(%s) error = %v, wantErr %t", tt.ip, err, tt.wantErr)
+					continue
+				}
+				if got != tt.want {
+					t.Errorf("lookupGeoIPDB(%s) = %+v, want %+v", tt.ip, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := parseTrustedProxies(" 10.0.0.0/8, 127.0.0.1 ,::1,, ::ffff:192.168.1.1, 172.16.5.4/12")
+	if err != nil {
+		t.Fatalf("parseTrustedProxies: %v", err)
+	}
+	want := []string{"10.0.0.0/8", "127.0.0.1/32", "::1/128", "192.168.1.1/32", "172.16.0.0/12"}
+	if len(prefixes) != len(want) {
+		t.Fatalf("prefixes = %v, want %v", prefixes, want)
+	}
+	for i, prefix := range prefixes {
+		if prefix.String() != want[i] {
+			t.Errorf("prefixes[%d] = %s, want %s", i, prefix, want[i])
+		}
+	}
+
+	for _, list := range []string{"10.0.0.0/33", "localhost", "10.0.0.1,proxy"} {
+		if _, err := parseTrustedProxies(list); err == nil {
+			t.Errorf("parseTrustedProxies(%q): expected error", list)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	previous := trustedProxies
+	t.Cleanup(func() { trustedProxies = previous })
+	proxies, err := parseTrustedProxies("127.0.0.1,::1,10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trustedProxies = proxies
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"diretto", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"diretto ignora XFF", "203.0.113.7:5000", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"diretto IPv6", "[2001:db8::1]:443", []string{"198.51.100.1"}, "2001:db8::1"},
+		{"IPv4 mappato", "[::ffff:203.0.113.7]:80", nil, "203.0.113.7"},
+		{"proxy senza XFF", "127.0.0.1:8080", nil, "127.0.0.1"},
+		{"proxy fidato", "127.0.0.1:8080", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"proxy IPv6 fidato", "[::1]:8080", []string{"2001:db8::2"}, "2001:db8::2"},
+		{"catena di proxy fidati", "127.0.0.1:8080", []string{"198.51.100.1, 10.0.0.2, 10.1.2.3"}, "198.51.100.1"},
+		{"client falsificato a sinistra", "127.0.0.1:8080", []string{"1.2.3.4, 198.51.100.1, 10.0.0.2"}, "198.51.100.1"},
+		{"header ripetuti", "127.0.0.1:8080", []string{"1.2.3.4", "198.51.100.1", "10.0.0.2"}, "198.51.100.1"},
+		{"hop non valido", "127.0.0.1:8080", []string{"198.51.100.1, garbage, 10.0.0.2"}, "10.0.0.2"},
+		{"solo proxy fidati", "127.0.0.1:8080", []string{"10.0.0.2, 10.0.0.3"}, "10.0.0.2"},
+		{"hop IPv4 mappato", "127.0.0.1:8080", []string{"::ffff:198.51.100.1"}, "198.51.100.1"},
+		{"indirizzo senza porta", "198.51.100.9", nil, "198.51.100.9"},
+		{"indirizzo non valido", "unix-socket", []string{"198.51.100.1"}, "invalid IP"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if got := clientIP(r).String(); got != tt.want {
+				t.Errorf("clientIP = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := map[string]bool{
+		"81.2.69.142": true,
+		"2a02:ff0::1": true,
+		"127.0.0.1":   false,
+		"10.1.2.3":    false,
+		"192.168.0.1": false,
+		"169.254.1.1": false,
+		"::1":         false,
+		"fe80::1":     false,
+		"fd00::1":     false,
+		"0.0.0.0":     false,
+	}
+	for ip, want := range tests {
+		if got := isPublicIP(netip.MustParseAddr(ip)); got != want {
+			t.Errorf("isPublicIP(%s) = %t, want %t", ip, got, want)
+		}
+	}
+	if isPublicIP(netip.Addr{}) {
+		t.Error("isPublicIP(zero) = true")
+	}
+}
+
+// useIPAPIServer punta ip-api sul server di test, con la cache vuota e senza database GeoIP
+func useIPAPIServer(t *testing.T, url string) {
+	t.Helper()
+	previousURL, previousCache, previousDB := ipAPIURL, ipCache, geoIPDB
+	t.Cleanup(func() { ipAPIURL, ipCache, geoIPDB = previousURL, previousCache, previousDB })
+
+	ipAPIURL = url + "/json/"
+	ipCache = newTTLCache[GeoLocation](time.Hour, 0, 10)
+	geoIPDB = nil
+}
+
+func TestLookupClientLocationIPAPI(t *testing.T) {
+	var paths []string
+	srv := newFixtureServer(t, `{"status":"success","lat":41.9,"lon":12.5,"city":"Roma","country":"Italia","timezone":"Europe/Rome"}`,
+		func(r *http.Request) { paths = append(paths, r.URL.Path) })
+	useIPAPIServer(t, srv.URL)
+
+	got, err := lookupClientLocation(context.Background(), netip.MustParseAddr("81.2.69.160"))
+	if err != nil {
+		t.Fatalf("lookupClientLocation: %v", err)
+	}
+	if got.City != "Roma" || got.Lat != 41.9 || got.Timezone != "Europe/Rome" {
+		t.Errorf("location = %+v", got)
+	}
+
+	// Un indirizzo privato geolocalizza il server, senza indirizzo nel percorso
+	if _, err := lookupClientLocation(context.Background(), netip.MustParseAddr("192.168.1.10")); err != nil {
+		t.Fatalf("lookupClientLocation privato: %v", err)
+	}
+	want := []string{"/json/81.2.69.160", "/json/"}
+	if fmt.Sprint(paths) != fmt.Sprint(want) {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+}
+
+func TestLookupClientLocationFailure(t *testing.T) {
+	useIPAPIServer(t, newFixtureServer(t, `{"status":"fail","message":"reserved range"}`, nil).URL)
+
+	if _, err := lookupClientLocation(context.Background(), netip.MustParseAddr("81.2.69.160")); err == nil {
+		t.Error("lookupClientLocation: expected error for status fail")
+	}
+}
+
+func TestLookupClientLocationCanceled(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+	useIPAPIServer(t, srv.URL)
+
+	// Una richiesta annullata dal visitatore non deve restare appesa a ip-api
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := lookupClientLocation(ctx, netip.MustParseAddr("81.2.69.160")); err == nil {
+		t.Fatal("lookupClientLocation: expected error")
+	}
+	if elapsed := time.Since(start); elapsed > ipAPITimeout {
+		t.Errorf("lookupClientLocation returned after %v", elapsed)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Identificativi delle posizioni salvate senza nome
+const (
+	customLocationID  = "custom"  // scelta sulla mappa
+	browserLocationID = "browser" // rilevata dal browser
+)
+
+// errLocationNotFound indica un identificativo di posizione inesistente
+var errLocationNotFound = fmt.Errorf("posizione non trovata")
+
+// SavedLocation rappresenta una posizione salvata con un nome
+type SavedLocation struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	Lat      float64 `json:"lat"`
+	Lon      float64 `json:"lon"`
+	Timezone string  `json:"timezone"`
+	Accuracy float64 `json:"accuracy,omitempty"` // metri, per le posizioni rilevate dal browser
+}
+
+// validate controlla nome, coordinate e fuso orario
+func (l *SavedLocation) validate() error {
+	l.Name = strings.TrimSpace(l.Name)
+	if l.Name == "" {
+		return fmt.Errorf("nome obbligatorio")
+	}
+	if l.Lat < -90 || l.Lat > 90 || l.Lon < -180 || l.Lon > 180 {
+		return fmt.Errorf("coordinate non valide")
+	}
+	if l.Timezone != "" && l.Timezone != autoTimezone {
+		if _, err := time.LoadLocation(l.Timezone); err != nil {
+			return fmt.Errorf("fuso orario non valido: %q", l.Timezone)
+		}
+	}
+	return nil
+}
+
+// listLocations restituisce una copia delle posizioni salvate e delle selezioni correnti
+func listLocations() LocationsResponse {
+	locationMutex.RLock()
+	defer locationMutex.RUnlock()
+
+	return LocationsResponse{
+		Locations:      append([]SavedLocation{}, savedLocations...),
+		ActiveID:       activeLocationID,
+		NotificationID: notificationLocationID,
+	}
+}
+
+// findLocation cerca una posizione salvata; va chiamata con locationMutex acquisito
+func findLocation(id string) (int, bool) {
+	for i, l := range savedLocations {
+		if l.ID == id {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// addLocation aggiunge una posizione salvata e le assegna un identificativo
+func addLocation(l SavedLocation) (SavedLocation, error) {
+	if err := l.validate(); err != nil {
+		return l, err
+	}
+
+	locationMutex.Lock()
+	defer locationMutex.Unlock()
+
+	nextLocationID++
+	l.ID = strconv.Itoa(nextLocationID)
+	savedLocations = append(savedLocations, l)
+	return l, nil
+}
+
+// putLocation crea o sostituisce la posizione con l'identificativo indicato
+func putLocation(l SavedLocation) (SavedLocation, error) {
+	if err := l.validate(); err != nil {
+		return l, err
+	}
+
+	locationMutex.Lock()
+	defer locationMutex.Unlock()
+
+	if i, ok := findLocation(l.ID); ok {
+		savedLocations[i] = l
+	} else {
+		savedLocations = append(savedLocations, l)
+	}
+	return l, nil
+}
+
+// updateLocation modifica una posizione salvata esistente
+func updateLocation(l SavedLocation) (SavedLocation, error) {
+	if err := l.validate(); err != nil {
+		return l, err
+	}
+
+	locationMutex.Lock()
+	defer locationMutex.Unlock()
+
+	i, ok := findLocation(l.ID)
+	if !ok {
+		return l, errLocationNotFound
+	}
+	savedLocations[i] = l
+	return l, nil
+}
+
+// deleteLocation rimuove una posizione salvata; se era selezionata
+// per la pagina o per le notifiche si torna alla posizione automatica
+func deleteLocation(id string) error {
+	locationMutex.Lock()
+	defer locationMutex.Unlock()
+
+	i, ok := findLocation(id)
+	if !ok {
+		return errLocationNotFound
+	}
+	savedLocations = append(savedLocations[:i], savedLocations[i+1:]...)
+	if activeLocationID == id {
+		activeLocationID = ""
+	}
+	if notificationLocationID == id {
+		notificationLocationID = ""
+	}
+	return nil
+}
+
+// selectLocation imposta la posizione mostrata (notify=false), dove id vuoto
+// indica quella automatica, o quella usata dalle notifiche (notify=true),
+// dove id vuoto indica di seguire la posizione mostrata
+func selectLocation(id string, notify bool) error {
+	locationMutex.Lock()
+	defer locationMutex.Unlock()
+
+	if id != "" {
+		if _, ok := findLocation(id); !ok {
+			return errLocationNotFound
+		}
+	}
+	if notify {
+		notificationLocationID = id
+	} else {
+		activeLocationID = id
+	}
+	return nil
+}
+
+// resolveSavedLocation restituisce la posizione salvata con l'identificativo
+// indicato o, se vuoto o inesistente, la geolocalizzazione di client; se anche
+// questa fallisce ripiega sulla posizione di default
+func resolveSavedLocation(ctx context.Context, id string, client netip.Addr) GeoLocation {
+	locationMutex.RLock()
+	i, ok := findLocation(id)
+	var saved SavedLocation
+	if ok {
+		saved = savedLocations[i]
+	}
+	locationMutex.RUnlock()
+
+	if !ok {
+		location, err := lookupClientLocation(ctx, client)
+		if err != nil {
+			log.Printf("⚠️ Geolocalizzazione IP fallita, uso la posizione di default: %v", err)
+			return resolveDefaultLocation(ctx)
+		}
+		location.Source = sourceIP
+		return location
+	}
+
+	city, country := getCityNameFromCoordinates(ctx, saved.Lat, saved.Lon)
+	if saved.ID != customLocationID && saved.ID != browserLocationID {
+		city = saved.Name
+	}
+	return GeoLocation{
+		Lat:      saved.Lat,
+		Lon:      saved.Lon,
+		City:     city,
+		Country:  country,
+		Timezone: saved.Timezone,
+		Accuracy: saved.Accuracy,
+		Source:   sourceSaved,
+	}
+}
@@ -9,15 +9,37 @@ import (
 func main() {
 	loadConfig()
 
-	// Attiva notifiche di default
-	startNotifications()
+	// Le impostazioni salvate dall'interfaccia prevalgono su .env
+	notificationsOn, err := loadSettings()
+	if err != nil {
+		log.Printf("⚠️ Impostazioni non caricate da %s: %v", settingsPath, err)
+	}
+
+	// Attiva notifiche di default, salvo se disattivate dall'interfaccia
+	if notificationsOn {
+		startNotifications()
+	}
 
 	http.HandleFunc("/", homeHandler)
+	http.HandleFunc("/place/{name}", homeHandler)
 	http.HandleFunc("/toggle-notification", toggleNotificationsHandler)
 	http.HandleFunc("/config", getConfigHandler)
 	http.HandleFunc("/config/update", updateConfigHandler)
 	http.HandleFunc("/location/set", setLocationHandler)
 	http.HandleFunc("/location/reset", resetLocationHandler)
+	http.HandleFunc("/location/browser", browserLocationHandler)
+	http.HandleFunc("/locations", locationsHandler)
+	http.HandleFunc("/locations/activate", activateLocationHandler)
+	http.HandleFunc("/locations/notify", notificationLocationHandler)
+	http.HandleFunc("/locations/{id}", locationHandler)
+	http.HandleFunc("/alerts", alertsHandler)
+	http.HandleFunc("/alerts/check", alertsCheckHandler)
+	http.HandleFunc("/alerts/{id}", alertHandler)
+	http.HandleFunc("/geocode/search", searchHandler)
+	http.HandleFunc("/forecast/hourly", hourlyHandler)
+	http.HandleFunc("/air-quality", airQualityHandler)
+	http.HandleFunc("/history", historyHandler)
+	http.HandleFunc("/cache/stats", cacheStatsHandler)
 
 	fmt.Printf("🌐 Server su %s\n", serverPort)
 	log.Fatal(http.ListenAndServe(serverPort, nil))
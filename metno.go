@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// metNoDefaultURL è l'endpoint locationforecast di MET Norway.
+// La variante complete include indice UV e probabilità di precipitazione.
+const metNoDefaultURL = "https://api.met.no/weatherdata/locationforecast/2.0/complete"
+
+// metNoProvider recupera le previsioni da MET Norway (api.met.no)
+type metNoProvider struct {
+	baseURL   string
+	userAgent string
+	client    *http.Client
+}
+
+// newMetNoProvider crea il provider MET Norway, con URL opzionale
+func newMetNoProvider(baseURL, userAgent string) *metNoProvider {
+	if baseURL == "" {
+		baseURL = metNoDefaultURL
+	}
+	if userAgent == "" {
+		userAgent = "go-meteo/" + AppVersion
+	}
+	return &metNoProvider{
+		baseURL:   baseURL,
+		userAgent: userAgent,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name restituisce il nome del provider
+func (p *metNoProvider) Name() string {
+	return "metno"
+}
+
+// metNoSummary è il riepilogo di un intervallo di previsione
+type metNoSummary struct {
+	Summary struct {
+		SymbolCode string `json:"symbol_code"`
+	} `json:"summary"`
+	Details struct {
+		PrecipitationAmount        float64 `json:"precipitation_amount"`
+		ProbabilityOfPrecipitation float64 `json:"probability_of_precipitation"`
+	} `json:"details"`
+}
+
+// metNoResponse è la parte della risposta locationforecast che ci interessa
+type metNoResponse struct {
+	Properties struct {
+		Timeseries []struct {
+			Time time.Time `json:"time"`
+			Data struct {
+				Instant struct {
+					Details struct {
+						AirTemperature   float64 `json:"air_temperature"`
+						RelativeHumidity float64 `json:"relative_humidity"`
+						WindSpeed        float64 `json:"wind_speed"`
+						UVIndexClearSky  float64 `json:"ultraviolet_index_clear_sky"`
+					} `json:"details"`
+				} `json:"instant"`
+				Next1Hours  *metNoSummary `json:"next_1_hours"`
+				Next6Hours  *metNoSummary `json:"next_6_hours"`
+				Next12Hours *metNoSummary `json:"next_12_hours"`
+			} `json:"data"`
+		} `json:"timeseries"`
+	} `json:"properties"`
+}
+
+// Forecast recupera la previsione e la converte nel modello comune
+func (p *metNoProvider) Forecast(ctx context.Context, q ForecastQuery) (*Forecast, error) {
+	// MET Norway accetta al massimo 4 decimali
+	url := fmt.Sprintf("%s?lat=%.4f&lon=%.4f", p.baseURL, q.Lat, q.Lon)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	// User-Agent obbligatorio secondo i termini d'uso di api.met.no
+	req.Header.Set("User-Agent", p.userAgent)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("met.no API status %d", resp.StatusCode)
+	}
+
+	var data metNoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	if len(data.Properties.Timeseries) == 0 {
+		return nil, fmt.Errorf("risposta met.no senza dati")
+	}
+
+	// met.no risponde in UTC: si usa il fuso richiesto o una stima dalla longitudine
+	loc := loadTimezone(q.Timezone)
+	if q.Timezone == "" || q.Timezone == autoTimezone {
+		loc = longitudeTimezone(q.Lon)
+	}
+
+	// met.no non fornisce la visibilità: HasVisibility resta false
+	forecast := &Forecast{
+		Provider: p.Name(),
+		Timezone: loc.String(),
+	}
+
+	dayIndex := map[string]int{}
+	for _, entry := range data.Properties.Timeseries {
+		t := entry.Time.In(loc)
+		details := entry.Data.Instant.Details
+
+		summary := entry.Data.Next1Hours
+		if summary == nil {
+			summary = entry.Data.Next6Hours
+		}
+		if summary == nil {
+			summary = entry.Data.Next12Hours
+		}
+		code := unknownWeatherCode
+		precipitation := 0.0
+		probability := 0.0
+		if summary != nil {
+			code = metNoSymbolToWMO(summary.Summary.SymbolCode)
+			precipitation = summary.Details.PrecipitationAmount
+			probability = summary.Details.ProbabilityOfPrecipitation
+		}
+		wind := details.WindSpeed * 3.6
+		apparent := apparentTemperature(details.AirTemperature, details.RelativeHumidity, details.WindSpeed)
+
+		// Solo le voci con intervallo orario finiscono nella serie oraria
+		if entry.Data.Next1Hours != nil {
+			forecast.Hourly = append(forecast.Hourly, HourlyPoint{
+				Time:          t,
+				Temperature:   details.AirTemperature,
+				WeatherCode:   code,
+				Precipitation: precipitation,
+				WindSpeed:     wind,
+				Humidity:      details.RelativeHumidity,
+
+				PrecipitationProbability: probability,
+				ApparentTemperature:      apparent,
+			})
+		}
+
+		key := t.Format("2006-01-02")
+		i, ok := dayIndex[key]
+		if !ok {
+			// Oltre Days si smette di aggregare i giorni, ma le voci orarie
+			// restano: la timeline di 48 ore va oltre un orizzonte di 1 giorno
+			if q.Days > 0 && len(forecast.Daily) == q.Days {
+				continue
+			}
+			date := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+			sunrise, sunset, _ := sunTimes(date, q.Lat, q.Lon)
+			forecast.Daily = append(forecast.Daily, DailyPoint{
+				Date:                     date,
+				TempMax:                  details.AirTemperature,
+				TempMin:                  details.AirTemperature,
+				WeatherCode:              code,
+				PrecipitationSum:         precipitation,
+				PrecipitationProbability: probability,
+				WindMax:                  wind,
+				UVIndexMax:               details.UVIndexClearSky,
+				Sunrise:                  sunrise,
+				Sunset:                   sunset,
+				Daylight:                 sunset.Sub(sunrise),
+			})
+			dayIndex[key] = len(forecast.Daily) - 1
+			continue
+		}
+
+		day := &forecast.Daily[i]
+		day.TempMax = max(day.TempMax, details.AirTemperature)
+		day.TempMin = min(day.TempMin, details.AirTemperature)
+		// Come Open-Meteo, il codice giornaliero è il più severo
+		day.WeatherCode = max(day.WeatherCode, code)
+		// Ogni voce copre l'intervallo fino alla successiva: niente doppi conteggi
+		day.PrecipitationSum += precipitation
+		day.WindMax = max(day.WindMax, wind)
+		day.PrecipitationProbability = max(day.PrecipitationProbability, probability)
+		day.UVIndexMax = max(day.UVIndexMax, details.UVIndexClearSky)
+	}
+
+	// met.no non ha un blocco current: si usa l'ora che contiene adesso
+	forecast.Current = forecast.hourlyAt(currentHourIndex(forecast.Hourly, time.Now()))
+
+	// met.no risponde solo in unità metriche
+	q.Units.convertMetric(forecast)
+
+	return forecast, nil
+}
+
+// metNoSymbolToWMO converte un symbol_code di MET Norway nel codice WMO più vicino
+func metNoSymbolToWMO(symbol string) int {
+	// Rimuove le varianti _day, _night e _polartwilight
+	if i := strings.Index(symbol, "_"); i >= 0 {
+		symbol = symbol[:i]
+	}
+
+	if strings.Contains(symbol, "thunder") {
+		return 95
+	}
+
+	codes := map[string]int{
+		"clearsky":          0,
+		"fair":              1,
+		"partlycloudy":      2,
+		"cloudy":            3,
+		"fog":               45,
+		"lightrain":         61,
+		"rain":              63,
+		"heavyrain":         65,
+		"lightrainshowers":  80,
+		"rainshowers":       81,
+		"heavyrainshowers":  82,
+		"lightsleet":        71,
+		"sleet":             73,
+		"heavysleet":        75,
+		"lightsnow":         71,
+		"snow":              73,
+		"heavysnow":         75,
+		"lightsleetshowers": 85,
+		"sleetshowers":      85,
+		"heavysleetshowers": 86,
+		"lightsnowshowers":  85,
+		"snowshowers":       85,
+		"heavysnowshowers":  86,
+	}
+	if code, ok := codes[symbol]; ok {
+		return code
+	}
+	return unknownWeatherCode
+}
+
+// apparentTemperature calcola la temperatura percepita (formula di Steadman,
+// la stessa usata da Open-Meteo) da °C, umidità relativa % e vento in m/s
+func apparentTemperature(celsius, humidity, windMs float64) float64 {
+	vapourPressure := humidity / 100 * 6.105 * math.Exp(17.27*celsius/(237.7+celsius))
+	return celsius + 0.33*vapourPressure - 0.70*windMs - 4.00
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// nominatimDefaultURL è l'istanza pubblica di Nominatim di OpenStreetMap
+const nominatimDefaultURL = "https://nominatim.openstreetmap.org"
+
+// nominatimLimiter limita a una richiesta al secondo le chiamate a Nominatim
+// dell'intero processo, come richiesto dalla usage policy di OSM. Oltre
+// qualche secondo di coda si rinuncia: chi chiama ripiega sul geocoder
+// successivo o sull'etichetta generica invece di bloccare la pagina.
+var nominatimLimiter = &rateLimiter{interval: time.Second, maxWait: 3 * time.Second}
+
+// errRateLimited indica che la coda del rate limiter è troppo lunga
+var errRateLimited = errors.New("troppe richieste in coda")
+
+// rateLimiter distanzia le richieste di almeno interval
+type rateLimiter struct {
+	interval time.Duration
+	maxWait  time.Duration // attesa massima in coda, 0 = illimitata
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// wait attende il proprio turno, o la cancellazione del contesto; se il
+// turno è più lontano di maxWait restituisce subito errRateLimited
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	if l.maxWait > 0 && slot.Sub(now) > l.maxWait {
+		l.mu.Unlock()
+		return errRateLimited
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := slot.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// nominatimGeocoder usa il servizio Nominatim di OpenStreetMap, identificandosi
+// con User-Agent ed email e salvando su disco i risultati del geocoding inverso
+type nominatimGeocoder struct {
+	baseURL   string
+	userAgent string
+	email     string
+}
+
+// newNominatimGeocoder crea il geocoder Nominatim, con URL e User-Agent opzionali
+func newNominatimGeocoder(baseURL, userAgent, email string) *nominatimGeocoder {
+	if baseURL == "" {
+		baseURL = nominatimDefaultURL
+	}
+	if userAgent == "" {
+		userAgent = "go-meteo/" + AppVersion
+	}
+	return &nominatimGeocoder{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		userAgent: userAgent,
+		email:     email,
+	}
+}
+
+// nominatimAddress è l'indirizzo strutturato restituito da Nominatim
+type nominatimAddress struct {
+	City    string `json:"city"`
+	Town    string `json:"town"`
+	Village string `json:"village"`
+	State   string `json:"state"`
+	Country string `json:"country"`
+}
+
+// place restituisce il nome della località più specifica disponibile
+func (a nominatimAddress) place() string {
+	for _, name := range []string{a.City, a.Town, a.Village} {
+		if name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// Name restituisce il nome del geocoder
+func (g *nominatimGeocoder) Name() string {
+	return "nominatim"
+}
+
+// get esegue una richiesta rispettando il limite di frequenza
+func (g *nominatimGeocoder) get(ctx context.Context, path string, params url.Values, out any) error {
+	if g.email != "" {
+		params.Set("email", g.email)
+	}
+	if err := nominatimLimiter.wait(ctx); err != nil {
+		return err
+	}
+	header := http.Header{}
+	header.Set("User-Agent", g.userAgent)
+	return getJSON(ctx, g.baseURL+path+"?"+params.Encode(), header, out)
+}
+
+// Search cerca le località per nome, ordinate per importanza
+func (g *nominatimGeocoder) Search(ctx context.Context, query string, limit int) ([]GeoLocation, error) {
+	params := url.Values{}
+	params.Set("format", "jsonv2")
+	params.Set("q", query)
+	params.Set("limit", strconv.Itoa(limit))
+	params.Set("addressdetails", "1")
+
+	var data []struct {
+		Lat     string           `json:"lat"`
+		Lon     string           `json:"lon"`
+		Name    string           `json:"name"`
+		Address nominatimAddress `json:"address"`
+	}
+	if err := g.get(ctx, "/search", params, &data); err != nil {
+		return nil, err
+	}
+
+	results := make([]GeoLocation, 0, len(data))
+	for _, r := range data {
+		lat, errLat := strconv.ParseFloat(r.Lat, 64)
+		lon, errLon := strconv.ParseFloat(r.Lon, 64)
+		if errLat != nil || errLon != nil {
+			continue
+		}
+		city := r.Address.place()
+		if city == "" {
+			city = r.Name
+		}
+		results = append(results, GeoLocation{
+			Lat:     lat,
+			Lon:     lon,
+			City:    city,
+			Admin:   r.Address.State,
+			Country: r.Address.Country,
+		})
+	}
+	return results, nil
+}
+
+// Reverse restituisce la località più vicina, dalla cache su disco se presente
+func (g *nominatimGeocoder) Reverse(ctx context.Context, lat, lon float64) (GeoLocation, error) {
+	key := locationKey(lat, lon)
+	if nominatimDiskCache != nil {
+		if place, ok := nominatimDiskCache.lookup(key); ok {
+			return place, nil
+		}
+	}
+
+	params := url.Values{}
+	params.Set("format", "jsonv2")
+	params.Set("lat", fmt.Sprintf("%.6f", lat))
+	params.Set("lon", fmt.Sprintf("%.6f", lon))
+
+	var data struct {
+		Address nominatimAddress `json:"address"`
+	}
+	if err := g.get(ctx, "/reverse", params, &data); err != nil {
+		return GeoLocation{}, err
+	}
+
+	city := data.Address.place()
+	if city == "" {
+		city = customLocationLabel
+	}
+	place := GeoLocation{
+		Lat:     lat,
+		Lon:     lon,
+		City:    city,
+		Admin:   data.Address.State,
+		Country: data.Address.Country,
+	}
+
+	if nominatimDiskCache != nil {
+		if err := nominatimDiskCache.store(key, place); err != nil {
+			log.Printf("⚠️ Cache Nominatim non salvata: %v", err)
+		}
+	}
+	return place, nil
+}
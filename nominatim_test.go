@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterSpacing(t *testing.T) {
+	l := &rateLimiter{interval: 20 * time.Millisecond}
+	start := time.Now()
+	for range 3 {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatalf("wait: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("3 richieste in %v, want >= 40ms", elapsed)
+	}
+}
+
+func TestRateLimiterMaxWait(t *testing.T) {
+	l := &rateLimiter{interval: time.Hour, maxWait: time.Minute}
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatalf("first wait: %v", err)
+	}
+
+	// Il turno successivo è tra un'ora: si rinuncia subito senza occupare la coda
+	start := time.Now()
+	for range 2 {
+		if err := l.wait(context.Background()); !errors.Is(err, errRateLimited) {
+			t.Fatalf("wait = %v, want errRateLimited", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("fail fast took %v", elapsed)
+	}
+	if next := time.Until(l.next); next > time.Hour {
+		t.Errorf("rejected waits advanced the queue to %v", next)
+	}
+}
+
+func TestRateLimiterCancel(t *testing.T) {
+	l := &rateLimiter{interval: time.Second, maxWait: 5 * time.Second}
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatalf("first wait: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := l.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wait = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("cancelled wait took %v", elapsed)
+	}
+}
+
+func TestNominatimReverse(t *testing.T) {
+	srv := newFixtureServer(t, `{"address":{"town":"Frascati","state":"Lazio","country":"Italia"}}`, func(r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/reverse" || q.Get("lat") != "41.808600" || q.Get("lon") != "12.680000" {
+			t.Errorf("request = %s", r.URL)
+		}
+		if q.Get("email") != "meteo@example.com" || r.Header.Get("User-Agent") != "test-agent" {
+			t.Errorf("identificazione mancante: email=%q ua=%q", q.Get("email"), r.Header.Get("User-Agent"))
+		}
+	})
+
+	g := newNominatimGeocoder(srv.URL+"/", "test-agent", "meteo@example.com")
+	place, err := g.Reverse(context.Background(), 41.8086, 12.68)
+	if err != nil {
+		t.Fatalf("Reverse: %v", err)
+	}
+	want := GeoLocation{Lat: 41.8086, Lon: 12.68, City: "Frascati", Admin: "Lazio", Country: "Italia"}
+	if place != want {
+		t.Errorf("Reverse = %+v, want %+v", place, want)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"time"
 )
@@ -13,33 +14,94 @@ func notificationWorker() {
 			log.Println("📢 Notifiche disattivate")
 			return
 		case <-ticker.C:
-			now := time.Now()
-			configMutex.RLock()
-			start := notificationStartHour
-			end := notificationEndHour
-			configMutex.RUnlock()
-
-			hour := now.Hour()
-			if hour < start || hour >= end {
-				log.Printf("⏱️ Fuori fascia (%02d:00–%02d:00), ora=%02d", start, end, hour)
+			// Il meteo serve prima della fascia oraria per conoscere il fuso della posizione
+			data, err := getNotificationWeather()
+			if err != nil {
+				log.Printf("❌ Errore meteo: %v", err)
 				continue
 			}
 
-			data, err := getWeather()
-			if err != nil {
-				log.Printf("❌ Errore meteo: %v", err)
+			if ok, window := inNotificationWindow(data.Timezone); !ok {
+				log.Printf("⏱️ Fuori fascia %s", window)
 				continue
 			}
 
-			if err := sendTelegramNotification(data); err != nil {
-				log.Printf("❌ Errore notifica: %v", err)
-			} else {
-				log.Println("✅ Notifica inviata")
+			sendScheduled(data)
+		}
+	}
+}
+
+// nowcastWorker controlla la pioggia in arrivo con un proprio ticker, più
+// frequente del riepilogo, finché stop non viene chiuso
+func nowcastWorker(stop <-chan bool, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			configMutex.RLock()
+			enabled := nowcastAlerts
+			configMutex.RUnlock()
+			if !enabled {
+				continue
 			}
+
+			// Basta la posizione: il meteo completo serve solo se parte un avviso
+			checkNowcast(resolveNotificationLocation())
 		}
 	}
 }
 
+// inNotificationWindow indica se l'ora attuale, nel fuso della posizione,
+// rientra nella fascia delle notifiche; window la descrive per i log
+func inNotificationWindow(timezone string) (ok bool, window string) {
+	configMutex.RLock()
+	start := notificationStartHour
+	end := notificationEndHour
+	configMutex.RUnlock()
+
+	hour := time.Now().In(loadTimezone(timezone)).Hour()
+	window = fmt.Sprintf("(%02d:00–%02d:00), ora=%02d %s", start, end, hour, timezone)
+	return hour >= start && hour < end, window
+}
+
+// sendScheduled invia la notifica periodica: con regole attive solo gli
+// avvisi scattati, altrimenti il riepilogo meteo
+func sendScheduled(data *WeatherData) {
+	if hasActiveAlertRules() {
+		sendAlerts(data)
+		return
+	}
+
+	if err := notifyAll(newWeatherNotification(data)); err != nil {
+		log.Printf("❌ Errore notifica: %v", err)
+	} else {
+		log.Println("✅ Notifica inviata")
+	}
+}
+
+// sendAlerts valuta le regole di avviso e invia quelle scattate; il cooldown
+// parte solo se almeno un canale ha ricevuto l'avviso
+func sendAlerts(data *WeatherData) {
+	now := time.Now()
+	events := evaluateAlerts(data, now)
+	if len(events) == 0 {
+		log.Println("🔕 Nessuna regola di avviso scattata")
+		return
+	}
+
+	if err := notifyAll(newAlertNotification(data, events)); err != nil {
+		log.Printf("❌ Errore avviso: %v", err)
+		return
+	}
+	markAlertsFired(events, now)
+	saveSettings()
+	log.Printf("⚠️ Avvisi inviati: %d", len(events))
+}
+
 // startNotifications avvia il sistema di notifiche periodiche
 func startNotifications() {
 	notificationsMutex.Lock()
@@ -51,6 +113,7 @@ func startNotifications() {
 
 	configMutex.RLock()
 	interval := notificationInterval
+	nowcastEvery := nowcastInterval
 	configMutex.RUnlock()
 
 	notificationsEnabled = true
@@ -60,19 +123,16 @@ func startNotifications() {
 	log.Printf("📢 Notifiche attivate (intervallo: %v)", interval)
 
 	go func() {
-		data, err := getWeather()
+		data, err := getNotificationWeather()
 		if err != nil {
 			log.Printf("❌ Errore meteo iniziale: %v", err)
 			return
 		}
-		if err := sendTelegramNotification(data); err != nil {
-			log.Printf("❌ Errore notifica iniziale: %v", err)
-		} else {
-			log.Println("✅ Notifica iniziale inviata")
-		}
+		sendScheduled(data)
 	}()
 
 	go notificationWorker()
+	go nowcastWorker(stopChan, nowcastEvery)
 }
 
 // stopNotifications ferma il sistema di notifiche periodiche
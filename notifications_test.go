@@ -0,0 +1,33 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSendScheduled(t *testing.T) {
+	previousNotifiers, previousPath := notifiers, settingsPath
+	t.Cleanup(func() { notifiers, settingsPath = previousNotifiers, previousPath })
+	settingsPath = filepath.Join(t.TempDir(), "settings.json")
+
+	fake := &fakeNotifier{name: "fake"}
+	notifiers = []Notifier{fake}
+
+	// Senza regole attive si invia il riepilogo
+	withAlertRules(t, AlertRule{ID: "1", Metric: "temperature", Operator: ">", Value: 0, CooldownMinutes: 60})
+	sendScheduled(testWeatherData())
+
+	// Con una regola attiva solo gli avvisi scattati, rispettando la pausa
+	withAlertRules(t, AlertRule{ID: "1", Metric: "temperature", Operator: ">", Value: 0, CooldownMinutes: 60, Enabled: true})
+	sendScheduled(testWeatherData())
+	sendScheduled(testWeatherData())
+
+	// Una regola attiva ma non verificata non invia nulla
+	withAlertRules(t, AlertRule{ID: "1", Metric: "temperature", Operator: "<", Value: 0, CooldownMinutes: 60, Enabled: true})
+	sendScheduled(testWeatherData())
+
+	if got := strings.Join(fake.events, ","); got != eventWeather+","+eventAlert {
+		t.Errorf("events = %s, want weather,alert", got)
+	}
+}
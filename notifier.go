@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// notifyTimeout limita la durata di un invio su ciascun canale
+const notifyTimeout = 30 * time.Second
+
+// timeoutNotifier è implementato dai canali che possono richiedere più di
+// notifyTimeout, ad esempio per i tentativi ripetuti dei webhook
+type timeoutNotifier interface {
+	timeout() time.Duration
+}
+
+// channelTimeout restituisce il tempo concesso all'invio su un canale
+func channelTimeout(n Notifier) time.Duration {
+	if t, ok := n.(timeoutNotifier); ok {
+		return max(notifyTimeout, t.timeout())
+	}
+	return notifyTimeout
+}
+
+// errNoNotifiers indica che nessun canale di notifica è configurato
+var errNoNotifiers = errors.New("nessun canale di notifica configurato")
+
+// Eventi che generano una notifica
+const (
+	eventWeather = "weather" // riepilogo periodico
+	eventAlert   = "alert"   // regole di avviso scattate
+	eventNowcast = "nowcast" // inizio o fine della pioggia a breve
+)
+
+// Notification è il messaggio consegnato dai canali: il riepilogo già
+// composto e i dati meteo da cui deriva
+type Notification struct {
+	Event   string
+	Summary weatherSummary
+	Data    *WeatherData
+	Alerts  []AlertEvent // solo per eventAlert ed eventNowcast
+}
+
+// newWeatherNotification prepara il riepilogo meteo periodico
+func newWeatherNotification(data *WeatherData) Notification {
+	return Notification{Event: eventWeather, Summary: buildSummary(data), Data: data}
+}
+
+// Notifier è un canale su cui consegnare le notifiche meteo
+type Notifier interface {
+	Name() string
+	// Notify invia la notifica con la formattazione del canale
+	Notify(ctx context.Context, n Notification) error
+}
+
+// newNotifiers costruisce i canali a partire da una lista separata da virgole;
+// un canale sconosciuto o senza configurazione viene saltato, così gli altri
+// restano attivi
+func newNotifiers(names string) []Notifier {
+	var list []Notifier
+	for _, name := range strings.Split(names, ",") {
+		var (
+			n   Notifier
+			err error
+		)
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "":
+			continue
+		case "telegram":
+			n, err = newTelegramNotifier(telegramBotToken, telegramChatID)
+		case "email", "smtp":
+			n, err = newEmailNotifierFromEnv()
+		case "discord":
+			n, err = newDiscordNotifierFromEnv()
+		case "slack":
+			n, err = newSlackNotifierFromEnv()
+		case "ntfy":
+			n, err = newNtfyNotifierFromEnv()
+		case "gotify":
+			n, err = newGotifyNotifierFromEnv()
+		case "webhook", "webhooks":
+			// Un canale per ogni webhook definito nel file
+			hooks, err := loadWebhookNotifiers(webhooksPath)
+			if err != nil {
+				log.Printf("⚠️ Canale %s non disponibile: %v", strings.TrimSpace(name), err)
+				continue
+			}
+			list = append(list, hooks...)
+			continue
+		default:
+			err = fmt.Errorf("canale sconosciuto")
+		}
+		if err != nil {
+			log.Printf("⚠️ Canale %s non disponibile: %v", strings.TrimSpace(name), err)
+			continue
+		}
+		list = append(list, n)
+	}
+	return list
+}
+
+// notifierNames restituisce i nomi dei canali configurati
+func notifierNames(list []Notifier) string {
+	if len(list) == 0 {
+		return "nessuno"
+	}
+	names := make([]string, len(list))
+	for i, n := range list {
+		names[i] = n.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// notifyAll invia la notifica in parallelo su tutti i canali, registrando
+// l'esito di ciascuno; restituisce errore solo se nessun invio è riuscito
+func notifyAll(notification Notification) error {
+	if len(notifiers) == 0 {
+		return errNoNotifiers
+	}
+
+	errs := make([]error, len(notifiers))
+	var wg sync.WaitGroup
+	for i, n := range notifiers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), channelTimeout(n))
+			defer cancel()
+			if err := n.Notify(ctx, notification); err != nil {
+				log.Printf("❌ Notifica %s non inviata: %v", n.Name(), err)
+				errs[i] = fmt.Errorf("%s: %w", n.Name(), err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// postJSON invia payload in JSON con gli header indicati; qualsiasi stato
+// 2xx è un successo, altrimenti l'errore riporta l'inizio della risposta
+func postJSON(ctx context.Context, requestURL string, header http.Header, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, requestURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set(contentTypeHeader, contentTypeJSON)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// homePageURL restituisce il link alla pagina del meteo per la posizione
+// della notifica, vuoto se PUBLIC_URL non è impostato
+func homePageURL(data *WeatherData) string {
+	configMutex.RLock()
+	base := publicURL
+	configMutex.RUnlock()
+	if base == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/?lat=%.4f&lon=%.4f", base, data.Lat, data.Lon)
+}
+
+// summarySection è un blocco del riepilogo meteo, con titolo e righe
+type summarySection struct {
+	Title string
+	Lines []string
+}
+
+// weatherSummary è il contenuto di una notifica, indipendente dal formato del
+// canale: ciascun Notifier lo rende con la formattazione della piattaforma
+type weatherSummary struct {
+	Title    string
+	Subtitle string
+	Sections []summarySection
+}
+
+// buildSummary raccoglie il riepilogo meteo inviato da tutti i canali
+func buildSummary(data *WeatherData) weatherSummary {
+	temp := data.Units.TemperatureLabel()
+	s := weatherSummary{
+		Title:    "🌤️ Meteo " + data.City,
+		Subtitle: fmt.Sprintf("🕐 %s (%s)", data.Time, data.Timezone),
+	}
+
+	s.Sections = append(s.Sections, summarySection{
+		Title: fmt.Sprintf("Condizioni Attuali (ore %s)", data.ObservedAt.Format("15:04")),
+		Lines: []string{
+			data.CurrentCondition,
+			fmt.Sprintf("🌡️ Temperatura: %.1f%s (percepita %.1f%s)", data.CurrentTemp, temp, data.FeelsLike, temp),
+			fmt.Sprintf("💧 Umidità: %.0f%%", data.Humidity),
+			fmt.Sprintf("💨 Vento: %.1f %s", data.WindSpeed, data.Units.WindLabel()),
+			fmt.Sprintf("🌧️ Precipitazioni: %.1f %s", data.Precipitation, data.Units.PrecipitationLabel()),
+		},
+	})
+
+	if len(data.Days) > 0 {
+		today := data.Days[0]
+		section := summarySection{Title: "Oggi", Lines: []string{
+			fmt.Sprintf("Max: %.1f%s | Min: %.1f%s", today.Max, temp, today.Min, temp),
+			fmt.Sprintf("🔆 UV max: %.1f", today.UVIndexMax),
+		}}
+		if !today.Sunrise.IsZero() {
+			section.Lines = append(section.Lines, fmt.Sprintf("🌅 Alba %s | 🌇 Tramonto %s (%s di luce)",
+				today.Sunrise.Format("15:04"), today.Sunset.Format("15:04"), today.Daylight))
+		}
+		s.Sections = append(s.Sections, section)
+	}
+
+	configMutex.RLock()
+	outlook := weeklyOutlook
+	aqiLevel := airQualityAlertAQI
+	pollenLevel := pollenAlertLevel
+	configMutex.RUnlock()
+
+	if aq := data.AirQuality; aq != nil && aq.exceeds(aqiLevel, pollenLevel) {
+		section := summarySection{Title: "Qualità dell'aria " + aq.Level, Lines: []string{
+			fmt.Sprintf("AQI europeo: %.0f | PM2.5: %.0f µg/m³ | PM10: %.0f µg/m³", aq.EuropeanAQI, aq.PM25, aq.PM10),
+		}}
+		if pollen := aq.MaxPollen(); pollenLevel > 0 && pollen.Value >= pollenLevel {
+			section.Lines = append(section.Lines, fmt.Sprintf("🌾 Pollini %s: %.0f granuli/m³", pollen.Name, pollen.Value))
+		}
+		s.Sections = append(s.Sections, section)
+	}
+
+	if outlook && len(data.Days) > 1 {
+		section := summarySection{Title: "Prossimi giorni"}
+		for _, day := range data.Days[1:] {
+			section.Lines = append(section.Lines, fmt.Sprintf("%s: %s %.0f°/%.0f%s, 🌧️ %.0f%%",
+				day.Label, day.Condition, day.Max, day.Min, temp, day.PrecipitationProbability))
+		}
+		s.Sections = append(s.Sections, section)
+	}
+
+	return s
+}
+
+// Text rende il riepilogo come testo semplice
+func (s weatherSummary) Text() string {
+	return s.Title + "\n" + s.Body()
+}
+
+// Body rende il riepilogo senza titolo, per i canali che lo inviano a parte
+func (s weatherSummary) Body() string {
+	var b strings.Builder
+	b.WriteString(s.Subtitle)
+	for _, section := range s.Sections {
+		b.WriteString("\n\n" + section.Title)
+		for _, line := range section.Lines {
+			b.WriteString("\n" + line)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testWeatherData restituisce dati meteo di esempio per i test dei canali
+func testWeatherData() *WeatherData {
+	return &WeatherData{
+		City:             "Roma",
+		Country:          "Italia",
+		Lat:              41.9028,
+		Lon:              12.4964,
+		Time:             "10:15 - 01/07/2026",
+		Timezone:         "Europe/Rome",
+		ObservedAt:       time.Date(2026, 7, 1, 10, 15, 0, 0, time.UTC),
+		WeatherCode:      61,
+		CurrentCondition: "🌦️ Pioggia leggera",
+		CurrentTemp:      24.5,
+		FeelsLike:        25.1,
+		Humidity:         55,
+		WindSpeed:        12,
+		Precipitation:    0.4,
+		Units:            Units{}.normalize(),
+	}
+}
+
+// testNotification restituisce una notifica con caratteri non ASCII e una
+// riga lunga, utile a verificare codifiche e troncamenti dei canali
+func testNotification() Notification {
+	return Notification{
+		Event: eventWeather,
+		Data:  testWeatherData(),
+		Summary: weatherSummary{
+			Title:    "🌤️ Meteo Roma",
+			Subtitle: "🕐 10:15 - 01/07/2026 (Europe/Rome)",
+			Sections: []summarySection{
+				{Title: "Condizioni Attuali", Lines: []string{
+					"🌦️ Pioggia leggera",
+					"💧 Umidità: 55%",
+					"Una riga molto lunga che supera i settantasei caratteri ammessi per riga dal quoted-printable",
+				}},
+				{Title: "Oggi", Lines: []string{"Max: 28.0°C | Min: 18.0°C"}},
+			},
+		},
+	}
+}
+
+// capturePayload avvia uno stand-in che decodifica in v il JSON ricevuto
+func capturePayload(t *testing.T, v any) string {
+	t.Helper()
+	srv := newFixtureServer(t, "", func(r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get(contentTypeHeader) != contentTypeJSON {
+			t.Errorf("request = %s %s", r.Method, r.Header.Get(contentTypeHeader))
+		}
+		if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+	})
+	return srv.URL
+}
+
+// fakeNotifier registra le notifiche ricevute e restituisce err
+type fakeNotifier struct {
+	name   string
+	err    error
+	calls  atomic.Int32
+	mu     sync.Mutex
+	events []string
+	last   Notification
+}
+
+func (f *fakeNotifier) Name() string { return f.name }
+
+func (f *fakeNotifier) Notify(ctx context.Context, n Notification) error {
+	f.calls.Add(1)
+	f.mu.Lock()
+	f.events = append(f.events, n.Event)
+	f.last = n
+	f.mu.Unlock()
+	if _, ok := ctx.Deadline(); !ok {
+		return errors.New("contesto senza scadenza")
+	}
+	return f.err
+}
+
+func TestNotifyAll(t *testing.T) {
+	previous := notifiers
+	t.Cleanup(func() { notifiers = previous })
+
+	notifiers = nil
+	if err := notifyAll(testNotification()); !errors.Is(err, errNoNotifiers) {
+		t.Errorf("no notifiers: err = %v, want errNoNotifiers", err)
+	}
+
+	// Basta un canale riuscito perché l'invio sia considerato riuscito
+	ok := &fakeNotifier{name: "ok"}
+	failing := &fakeNotifier{name: "ko", err: errors.New("down")}
+	notifiers = []Notifier{failing, ok}
+	if err := notifyAll(testNotification()); err != nil {
+		t.Errorf("partial failure: err = %v, want nil", err)
+	}
+	if ok.calls.Load() != 1 || failing.calls.Load() != 1 {
+		t.Errorf("calls = %d/%d, want 1/1", ok.calls.Load(), failing.calls.Load())
+	}
+
+	other := &fakeNotifier{name: "ko2", err: errors.New("timeout")}
+	notifiers = []Notifier{failing, other}
+	err := notifyAll(testNotification())
+	if err == nil || !strings.Contains(err.Error(), "ko: down") || !strings.Contains(err.Error(), "ko2: timeout") {
+		t.Errorf("all failing: err = %v", err)
+	}
+}
+
+func TestNewNotifiersSkipsUnavailable(t *testing.T) {
+	t.Setenv("SMTP_HOST", "")
+	t.Setenv("DISCORD_WEBHOOK_URL", "https://discord.example/webhook")
+
+	list := newNotifiers("email, unknown, discord,,")
+	if got := notifierNames(list); got != "discord" {
+		t.Errorf("notifiers = %s, want discord", got)
+	}
+	if got := notifierNames(nil); got != "nessuno" {
+		t.Errorf("notifierNames(nil) = %s", got)
+	}
+}
+
+func TestWeatherSummaryText(t *testing.T) {
+	s := weatherSummary{
+		Title:    "Titolo",
+		Subtitle: "Sottotitolo",
+		Sections: []summarySection{{Title: "A", Lines: []string{"1", "2"}}, {Title: "B"}},
+	}
+	want := "Titolo\nSottotitolo\n\nA\n1\n2\n\nB"
+	if got := s.Text(); got != want {
+		t.Errorf("Text = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hectormalot/omgo"
+)
+
+// nowcastStep è la durata di un passo dei dati minutely_15
+const nowcastStep = 15 * time.Minute
+
+// NowcastStep è la precipitazione prevista nei 15 minuti che terminano a Time
+type NowcastStep struct {
+	Time          time.Time
+	Precipitation float64 // mm
+}
+
+// Nowcast è la previsione delle precipitazioni a 15 minuti
+type Nowcast struct {
+	Timezone string
+	Steps    []NowcastStep
+}
+
+// rainTransition è un passaggio da asciutto a pioggia o viceversa
+type rainTransition struct {
+	Start bool      // true = inizio della pioggia, false = fine
+	At    time.Time // inizio del primo intervallo di 15 minuti nel nuovo stato
+	Peak  float64   // intensità massima attesa in mm/h, solo per l'inizio
+}
+
+// nowcastRecord è l'ultimo avviso inviato per una posizione
+type nowcastRecord struct {
+	key        string
+	transition rainTransition
+}
+
+// nowcastSent ricorda l'ultimo avviso inviato, per non ripeterlo a ogni tick
+var (
+	nowcastSent  nowcastRecord
+	nowcastMutex sync.Mutex
+)
+
+// repeats indica se t è lo stesso evento già inviato: stessa posizione, stesso
+// tipo e orario non oltre lookahead dal precedente, perché la previsione può
+// spostarsi di qualche intervallo tra un controllo e l'altro
+func (r nowcastRecord) repeats(key string, t rainTransition, lookahead time.Duration) bool {
+	return r.key == key && r.transition.Start == t.Start && t.At.Before(r.transition.At.Add(lookahead))
+}
+
+// getNowcast recupera la previsione a 15 minuti, usando la cache
+func getNowcast(lat, lon float64) (*Nowcast, error) {
+	return nowcastCache.get(context.Background(), locationKey(lat, lon), func(ctx context.Context) (*Nowcast, error) {
+		return fetchNowcast(ctx, lat, lon)
+	})
+}
+
+// fetchNowcast interroga Open-Meteo per le precipitazioni minutely_15, in mm
+func fetchNowcast(ctx context.Context, lat, lon float64) (*Nowcast, error) {
+	req, err := omgo.NewForecastRequest(lat, lon)
+	if err != nil {
+		return nil, err
+	}
+	// Due giorni, così la finestra resta coperta anche vicino alla mezzanotte
+	req.WithMinutely15(omgo.Minutely15Precipitation).
+		WithTimezone(autoTimezone).
+		WithForecastDays(2)
+
+	weather, err := newOpenMeteoProvider(openMeteoURL).client.Forecast(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	m := weather.Minutely15
+	if m == nil || len(m.Precipitation) == 0 {
+		return nil, fmt.Errorf("dati a 15 minuti non disponibili")
+	}
+
+	nowcast := &Nowcast{Timezone: weather.Timezone}
+	for i, t := range m.Times {
+		if i >= len(m.Precipitation) {
+			break
+		}
+		nowcast.Steps = append(nowcast.Steps, NowcastStep{Time: t, Precipitation: m.Precipitation[i]})
+	}
+	return nowcast, nil
+}
+
+// transition cerca il primo cambio di stato entro lookahead: lo stato attuale
+// è quello dell'intervallo che contiene now, piove se si superano wetMM
+func (n *Nowcast) transition(now time.Time, lookahead time.Duration, wetMM float64) (rainTransition, bool) {
+	current := -1
+	for i, step := range n.Steps {
+		if step.Time.After(now) {
+			current = i
+			break
+		}
+	}
+	if current < 0 {
+		return rainTransition{}, false
+	}
+
+	wetNow := n.Steps[current].Precipitation >= wetMM
+	limit := now.Add(lookahead)
+	for i := current + 1; i < len(n.Steps); i++ {
+		start := n.Steps[i].Time.Add(-nowcastStep)
+		if start.After(limit) {
+			break
+		}
+		wet := n.Steps[i].Precipitation >= wetMM
+		if wet == wetNow {
+			continue
+		}
+
+		t := rainTransition{Start: wet, At: start}
+		for j := i; wet && j < len(n.Steps) && n.Steps[j].Precipitation >= wetMM; j++ {
+			t.Peak = max(t.Peak, n.Steps[j].Precipitation*float64(time.Hour/nowcastStep))
+		}
+		return t, true
+	}
+	return rainTransition{}, false
+}
+
+// rainIntensityLabel classifica l'intensità della pioggia in mm/h
+func rainIntensityLabel(mmh float64) string {
+	switch {
+	case mmh < 2.5:
+		return "debole"
+	case mmh < 7.6:
+		return "moderata"
+	case mmh < 50:
+		return "forte"
+	}
+	return "violenta"
+}
+
+// checkNowcast invia un avviso quando la pioggia sta per iniziare o finire
+// nella posizione delle notifiche; lo stesso evento, anche se la previsione
+// si sposta di qualche minuto tra un tick e l'altro, viene inviato una volta sola
+func checkNowcast(location GeoLocation) {
+	configMutex.RLock()
+	enabled := nowcastAlerts
+	lookahead := nowcastLookahead
+	wetMM := nowcastWetMM
+	configMutex.RUnlock()
+	if !enabled {
+		return
+	}
+
+	nowcast, err := getNowcast(location.Lat, location.Lon)
+	if err != nil {
+		log.Printf("⚠️ Previsione a 15 minuti non disponibile: %v", err)
+		return
+	}
+	// Fuori fascia si tace senza log, per non ripeterlo ogni pochi minuti
+	if ok, _ := inNotificationWindow(nowcast.Timezone); !ok {
+		return
+	}
+
+	now := time.Now()
+	t, ok := nowcast.transition(now, lookahead, wetMM)
+	if !ok {
+		return
+	}
+
+	key := locationKey(location.Lat, location.Lon)
+	nowcastMutex.Lock()
+	last := nowcastSent
+	nowcastMutex.Unlock()
+	if last.repeats(key, t, lookahead) {
+		return
+	}
+
+	data, err := getWeatherAt(location)
+	if err != nil {
+		// L'avviso non deve saltare per un errore delle previsioni
+		log.Printf("⚠️ Meteo non disponibile per l'avviso pioggia: %v", err)
+		data = nowcastWeatherData(location, nowcast, now)
+	}
+	if err := notifyAll(newNowcastNotification(data, t, now)); err != nil {
+		log.Printf("❌ Errore avviso pioggia: %v", err)
+		return
+	}
+
+	nowcastMutex.Lock()
+	nowcastSent = nowcastRecord{key: key, transition: t}
+	nowcastMutex.Unlock()
+	log.Printf("🌧️ Avviso pioggia inviato: inizio=%t alle %s", t.Start, t.At.Format("15:04"))
+}
+
+// nowcastWeatherData ricava dalla posizione i dati minimi per l'avviso pioggia,
+// quando le previsioni complete non sono disponibili
+func nowcastWeatherData(location GeoLocation, nowcast *Nowcast, now time.Time) *WeatherData {
+	configMutex.RLock()
+	u := units
+	configMutex.RUnlock()
+
+	return &WeatherData{
+		City:           location.City,
+		Country:        location.Country,
+		Lat:            location.Lat,
+		Lon:            location.Lon,
+		LocationSource: location.Source,
+		Time:           now.In(loadTimezone(nowcast.Timezone)).Format("15:04 - 02/01/2006"),
+		Timezone:       nowcast.Timezone,
+		WeatherCode:    unknownWeatherCode,
+		Units:          u,
+		Version:        AppVersion,
+	}
+}
+
+// newNowcastNotification prepara l'avviso di inizio o fine della pioggia
+func newNowcastNotification(data *WeatherData, t rainTransition, now time.Time) Notification {
+	at := t.At.In(loadTimezone(data.Timezone))
+	minutes := int(at.Sub(now).Round(time.Minute) / time.Minute)
+	when := fmt.Sprintf("alle %s (tra circa %d minuti)", at.Format("15:04"), max(minutes, 0))
+
+	event := AlertEvent{RuleID: "nowcast", At: at}
+	summary := weatherSummary{Subtitle: fmt.Sprintf("🕐 %s (%s)", data.Time, data.Timezone)}
+	if t.Start {
+		peak := data.Units.precipitation(t.Peak)
+		event.Name = "🌧️ Pioggia in arrivo"
+		event.Value = peak
+		event.Message = fmt.Sprintf("Inizio previsto %s, intensità %s (fino a %.1f %s/h)",
+			when, rainIntensityLabel(t.Peak), peak, data.Units.PrecipitationLabel())
+	} else {
+		event.Name = "🌤️ Fine della pioggia"
+		event.Message = "Fine prevista " + when
+	}
+
+	summary.Title = event.Name + " a " + data.City
+	summary.Sections = []summarySection{{Title: event.Name, Lines: []string{event.Message}}}
+	return Notification{Event: eventNowcast, Summary: summary, Data: data, Alerts: []AlertEvent{event}}
+}
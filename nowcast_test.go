@@ -0,0 +1,185 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testNowcast crea una previsione a 15 minuti a partire da start: ogni valore
+// è la pioggia dell'intervallo che termina 15 minuti dopo il precedente
+func testNowcast(start time.Time, mm ...float64) *Nowcast {
+	n := &Nowcast{Timezone: "UTC"}
+	for i, v := range mm {
+		n.Steps = append(n.Steps, NowcastStep{Time: start.Add(time.Duration(i+1) * nowcastStep), Precipitation: v})
+	}
+	return n
+}
+
+func TestNowcastTransition(t *testing.T) {
+	base := time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC)
+	now := base.Add(5 * time.Minute) // nel primo intervallo, 12:00–12:15
+
+	for _, c := range []struct {
+		name      string
+		mm        []float64
+		now       time.Time
+		lookahead time.Duration
+		want      rainTransition
+		ok        bool
+	}{
+		{"da asciutto a pioggia", []float64{0, 0, 0.5, 1.5, 0}, now, time.Hour, rainTransition{Start: true, At: base.Add(30 * time.Minute), Peak: 6}, true},
+		{"da pioggia ad asciutto", []float64{0.4, 0.3, 0, 0.2}, now, time.Hour, rainTransition{Start: false, At: base.Add(30 * time.Minute)}, true},
+		{"sempre asciutto", []float64{0, 0, 0.05, 0}, now, time.Hour, rainTransition{}, false},
+		{"sempre pioggia", []float64{1, 2, 1}, now, time.Hour, rainTransition{}, false},
+		{"oltre la finestra", []float64{0, 0, 0, 0, 0, 1}, now, time.Hour, rainTransition{}, false},
+		{"al limite della finestra", []float64{0, 0, 0, 0, 0.2}, now, time.Hour, rainTransition{Start: true, At: base.Add(time.Hour), Peak: 0.8}, true},
+		// Lo stato attuale è quello dell'intervallo che contiene now
+		{"intervallo successivo", []float64{1, 0, 0}, base.Add(20 * time.Minute), time.Hour, rainTransition{}, false},
+		{"previsione scaduta", []float64{0, 1}, base.Add(2 * time.Hour), time.Hour, rainTransition{}, false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			got, ok := testNowcast(base, c.mm...).transition(c.now, c.lookahead, 0.1)
+			if ok != c.ok || !got.At.Equal(c.want.At) || got.Start != c.want.Start || got.Peak != c.want.Peak {
+				t.Errorf("transition = %+v, %v, want %+v, %v", got, ok, c.want, c.ok)
+			}
+		})
+	}
+}
+
+func TestNowcastRecordRepeats(t *testing.T) {
+	at := time.Date(2026, 7, 1, 12, 30, 0, 0, time.UTC)
+	last := nowcastRecord{key: "41.90,12.50", transition: rainTransition{Start: true, At: at}}
+
+	for _, c := range []struct {
+		name string
+		key  string
+		t    rainTransition
+		want bool
+	}{
+		{"stesso evento", "41.90,12.50", rainTransition{Start: true, At: at}, true},
+		{"previsione spostata", "41.90,12.50", rainTransition{Start: true, At: at.Add(45 * time.Minute)}, true},
+		{"previsione anticipata", "41.90,12.50", rainTransition{Start: true, At: at.Add(-15 * time.Minute)}, true},
+		{"nuovo evento oltre la finestra", "41.90,12.50", rainTransition{Start: true, At: at.Add(time.Hour)}, false},
+		{"fine della pioggia", "41.90,12.50", rainTransition{Start: false, At: at.Add(30 * time.Minute)}, false},
+		{"altra posizione", "45.46,9.19", rainTransition{Start: true, At: at}, false},
+	} {
+		if got := last.repeats(c.key, c.t, time.Hour); got != c.want {
+			t.Errorf("%s: repeats = %v, want %v", c.name, got, c.want)
+		}
+	}
+	if (nowcastRecord{}).repeats("41.90,12.50", rainTransition{Start: false}, time.Hour) {
+		t.Error("nessun avviso precedente: non è una ripetizione")
+	}
+}
+
+// useNowcastTest prepara checkNowcast con la fascia indicata, un canale finto,
+// una cache vuota e un provider meteo che fallisce sempre
+func useNowcastTest(t *testing.T, startHour, endHour int) *fakeNotifier {
+	t.Helper()
+	configMutex.Lock()
+	enabled, lookahead, wetMM := nowcastAlerts, nowcastLookahead, nowcastWetMM
+	start, end := notificationStartHour, notificationEndHour
+	nowcastAlerts, nowcastLookahead, nowcastWetMM = true, time.Hour, 0.1
+	notificationStartHour, notificationEndHour = startHour, endHour
+	configMutex.Unlock()
+	previousCache, previousNotifiers, previousProvider := nowcastCache, notifiers, weatherProvider
+	nowcastMutex.Lock()
+	previousSent := nowcastSent
+	nowcastSent = nowcastRecord{}
+	nowcastMutex.Unlock()
+	t.Cleanup(func() {
+		configMutex.Lock()
+		nowcastAlerts, nowcastLookahead, nowcastWetMM = enabled, lookahead, wetMM
+		notificationStartHour, notificationEndHour = start, end
+		configMutex.Unlock()
+		nowcastCache, notifiers, weatherProvider = previousCache, previousNotifiers, previousProvider
+		nowcastMutex.Lock()
+		nowcastSent = previousSent
+		nowcastMutex.Unlock()
+	})
+
+	fake := &fakeNotifier{name: "fake"}
+	notifiers = []Notifier{fake}
+	nowcastCache = newTTLCache[*Nowcast](time.Hour, 0, 0)
+	weatherProvider = newOpenMeteoProvider(newStatusServer(t, http.StatusServiceUnavailable).URL)
+	return fake
+}
+
+// testNowcastLocation è la posizione delle notifiche usata nei test del nowcast
+var testNowcastLocation = GeoLocation{Lat: 41.9028, Lon: 12.4964, City: "Roma", Country: "Italia"}
+
+func TestCheckNowcastSendsOnce(t *testing.T) {
+	fake := useNowcastTest(t, 0, 24)
+	key := locationKey(testNowcastLocation.Lat, testNowcastLocation.Lon)
+	start := time.Now().Truncate(nowcastStep)
+
+	// La previsione in cache evita le richieste a Open-Meteo
+	check := func(mm ...float64) {
+		nowcastCache.set(key, testNowcast(start, mm...))
+		checkNowcast(testNowcastLocation)
+	}
+	check(0, 0, 1, 1)    // pioggia tra 30 minuti: avviso
+	check(0, 0, 1, 1)    // stessa previsione: nessun avviso
+	check(0, 0, 0, 1, 1) // spostata di 15 minuti: stesso evento
+	check(1, 1, 1, 0)    // fine della pioggia: nuovo avviso
+	check(0, 0, 0, 0)    // nessun cambio: nessun avviso
+
+	if got := strings.Join(fake.events, ","); got != eventNowcast+","+eventNowcast {
+		t.Errorf("events = %s, want two nowcast alerts", got)
+	}
+	if !nowcastSent.transition.At.Equal(start.Add(45*time.Minute)) || nowcastSent.transition.Start {
+		t.Errorf("last sent = %+v", nowcastSent)
+	}
+
+	// Senza previsioni l'avviso parte comunque, con i dati della posizione
+	data := fake.last.Data
+	if data == nil || data.City != "Roma" || data.Timezone != "UTC" || data.WeatherCode != unknownWeatherCode {
+		t.Fatalf("fallback data = %+v", data)
+	}
+	if want := "🌤️ Fine della pioggia a Roma"; fake.last.Summary.Title != want {
+		t.Errorf("title = %q, want %q", fake.last.Summary.Title, want)
+	}
+}
+
+func TestCheckNowcastOutsideWindow(t *testing.T) {
+	fake := useNowcastTest(t, 0, 0)
+	start := time.Now().Truncate(nowcastStep)
+
+	// Il fuso per la fascia oraria arriva dal nowcast, senza scaricare il meteo
+	nowcastCache.set(locationKey(testNowcastLocation.Lat, testNowcastLocation.Lon), testNowcast(start, 0, 0, 1, 1))
+	checkNowcast(testNowcastLocation)
+	if got := fake.calls.Load(); got != 0 {
+		t.Errorf("calls = %d, want 0 outside the window", got)
+	}
+}
+
+func TestInNotificationWindow(t *testing.T) {
+	configMutex.Lock()
+	start, end := notificationStartHour, notificationEndHour
+	configMutex.Unlock()
+	t.Cleanup(func() {
+		configMutex.Lock()
+		notificationStartHour, notificationEndHour = start, end
+		configMutex.Unlock()
+	})
+
+	hour := time.Now().UTC().Hour()
+	for _, c := range []struct {
+		start, end int
+		want       bool
+	}{
+		{0, 24, true},
+		{hour, hour + 1, true},
+		{hour + 1, hour + 1, false},
+		{0, hour, false},
+	} {
+		configMutex.Lock()
+		notificationStartHour, notificationEndHour = c.start, c.end
+		configMutex.Unlock()
+		if ok, window := inNotificationWindow("UTC"); ok != c.want {
+			t.Errorf("%02d–%02d alle %02d: ok = %v (%s), want %v", c.start, c.end, hour, ok, window, c.want)
+		}
+	}
+}
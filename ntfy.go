@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ntfyDefaultURL è il server pubblico di ntfy
+const ntfyDefaultURL = "https://ntfy.sh"
+
+// ntfyNotifier pubblica le notifiche su un topic di ntfy
+type ntfyNotifier struct {
+	serverURL string
+	topic     string
+	token     string // access token, in alternativa a username e password
+	username  string
+	password  string
+	priority  int // da 1 (minima) a 5 (massima)
+	tags      []string
+}
+
+// newNtfyNotifierFromEnv crea il canale ntfy dalle variabili NTFY_*
+func newNtfyNotifierFromEnv() (*ntfyNotifier, error) {
+	n := &ntfyNotifier{
+		serverURL: strings.TrimSuffix(os.Getenv("NTFY_URL"), "/"),
+		topic:     os.Getenv("NTFY_TOPIC"),
+		token:     os.Getenv("NTFY_TOKEN"),
+		username:  os.Getenv("NTFY_USERNAME"),
+		password:  os.Getenv("NTFY_PASSWORD"),
+		priority:  min(envInt("NTFY_PRIORITY", 3), 5),
+	}
+	for _, tag := range strings.Split(os.Getenv("NTFY_TAGS"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			n.tags = append(n.tags, tag)
+		}
+	}
+	if n.serverURL == "" {
+		n.serverURL = ntfyDefaultURL
+	}
+	if n.topic == "" {
+		return nil, fmt.Errorf("NTFY_TOPIC non impostato")
+	}
+	return n, nil
+}
+
+// Name restituisce il nome del canale
+func (n *ntfyNotifier) Name() string {
+	return "ntfy"
+}
+
+// ntfyWeatherTag restituisce il tag ntfy, mostrato come emoji, del codice WMO
+func ntfyWeatherTag(code int) string {
+	switch {
+	case code == unknownWeatherCode:
+		return "grey_question"
+	case code <= 1:
+		return "sunny"
+	case code == 2:
+		return "partly_sunny"
+	case code == 3:
+		return "cloud"
+	case code == 45 || code == 48:
+		return "fog"
+	case code >= 71 && code <= 77, code == 85 || code == 86:
+		return "snowflake"
+	case code >= 95:
+		return "zap"
+	default:
+		return "umbrella"
+	}
+}
+
+// Notify pubblica il riepilogo meteo con titolo, priorità, tag e link alla pagina
+func (n *ntfyNotifier) Notify(ctx context.Context, notification Notification) error {
+	summary, data := notification.Summary, notification.Data
+
+	payload := map[string]interface{}{
+		"topic":    n.topic,
+		"title":    summary.Title,
+		"message":  summary.Body(),
+		"priority": n.priority,
+		"tags":     append([]string{ntfyWeatherTag(data.WeatherCode)}, n.tags...),
+	}
+	if link := homePageURL(data); link != "" {
+		payload["click"] = link
+	}
+
+	header := http.Header{}
+	switch {
+	case n.token != "":
+		header.Set("Authorization", "Bearer "+n.token)
+	case n.username != "":
+		credentials := base64.StdEncoding.EncodeToString([]byte(n.username + ":" + n.password))
+		header.Set("Authorization", "Basic "+credentials)
+	}
+	return postJSON(ctx, n.serverURL, header, payload)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+// ntfyPayload è il corpo JSON pubblicato sul server ntfy
+type ntfyPayload struct {
+	Topic    string   `json:"topic"`
+	Title    string   `json:"title"`
+	Message  string   `json:"message"`
+	Priority int      `json:"priority"`
+	Tags     []string `json:"tags"`
+	Click    string   `json:"click"`
+}
+
+// captureNtfy avvia uno stand-in di ntfy che registra corpo e Authorization
+func captureNtfy(t *testing.T, payload *ntfyPayload, auth *string) string {
+	t.Helper()
+	srv := newFixtureServer(t, "", func(r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		*auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+	})
+	return srv.URL
+}
+
+func TestNtfyNotify(t *testing.T) {
+	previous := publicURL
+	publicURL = "https://meteo.example.com"
+	t.Cleanup(func() { publicURL = previous })
+
+	var payload ntfyPayload
+	var auth string
+	n := &ntfyNotifier{topic: "meteo", priority: 4, tags: []string{"casa"}}
+	n.serverURL = captureNtfy(t, &payload, &auth)
+
+	notification := testNotification()
+	if err := n.Notify(context.Background(), notification); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	if payload.Topic != "meteo" || payload.Title != "🌤️ Meteo Roma" || payload.Priority != 4 {
+		t.Errorf("payload = %+v", payload)
+	}
+	if payload.Message != notification.Summary.Body() {
+		t.Errorf("message = %q", payload.Message)
+	}
+	// Il tag del meteo precede quelli configurati
+	if want := []string{"umbrella", "casa"}; !slices.Equal(payload.Tags, want) {
+		t.Errorf("tags = %v, want %v", payload.Tags, want)
+	}
+	if want := "https://meteo.example.com/?lat=41.9028&lon=12.4964"; payload.Click != want {
+		t.Errorf("click = %q, want %q", payload.Click, want)
+	}
+	if auth != "" {
+		t.Errorf("Authorization = %q, want none", auth)
+	}
+}
+
+func TestNtfyNotifyAuth(t *testing.T) {
+	previous := publicURL
+	publicURL = ""
+	t.Cleanup(func() { publicURL = previous })
+
+	tests := []struct {
+		name     string
+		notifier ntfyNotifier
+		want     string
+	}{
+		{"token", ntfyNotifier{token: "tk_abc"}, "Bearer tk_abc"},
+		{"utente", ntfyNotifier{username: "mario", password: "segreta"}, "Basic bWFyaW86c2VncmV0YQ=="},
+		// Il token prevale sulle credenziali
+		{"entrambi", ntfyNotifier{token: "tk_abc", username: "mario", password: "segreta"}, "Bearer tk_abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var payload ntfyPayload
+			var auth string
+			n := tt.notifier
+			n.topic = "meteo"
+			n.serverURL = captureNtfy(t, &payload, &auth)
+
+			if err := n.Notify(context.Background(), testNotification()); err != nil {
+				t.Fatalf("Notify: %v", err)
+			}
+			if auth != tt.want {
+				t.Errorf("Authorization = %q, want %q", auth, tt.want)
+			}
+			// Senza PUBLIC_URL non c'è un link da aprire
+			if payload.Click != "" {
+				t.Errorf("click = %q, want empty", payload.Click)
+			}
+		})
+	}
+}
+
+func TestNtfyWeatherTag(t *testing.T) {
+	tests := []struct {
+		code int
+		want string
+	}{
+		{0, "sunny"},
+		{2, "partly_sunny"},
+		{3, "cloud"},
+		{45, "fog"},
+		{63, "umbrella"},
+		{73, "snowflake"},
+		{95, "zap"},
+		// Un simbolo senza equivalente WMO non deve apparire come sereno
+		{unknownWeatherCode, "grey_question"},
+	}
+	for _, tt := range tests {
+		if got := ntfyWeatherTag(tt.code); got != tt.want {
+			t.Errorf("ntfyWeatherTag(%d) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
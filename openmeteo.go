@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hectormalot/omgo"
+)
+
+// openMeteoProvider recupera le previsioni da Open-Meteo tramite omgo
+type openMeteoProvider struct {
+	client *omgo.Client
+}
+
+// newOpenMeteoProvider crea il provider Open-Meteo, con URL opzionale
+func newOpenMeteoProvider(baseURL string) *openMeteoProvider {
+	var opts []omgo.Option
+	if baseURL != "" {
+		opts = append(opts, omgo.WithForecastURL(baseURL))
+	}
+	return &openMeteoProvider{client: omgo.NewClient(opts...)}
+}
+
+// Name restituisce il nome del provider
+func (p *openMeteoProvider) Name() string {
+	return "openmeteo"
+}
+
+// Forecast recupera la previsione oraria e giornaliera
+func (p *openMeteoProvider) Forecast(ctx context.Context, q ForecastQuery) (*Forecast, error) {
+	req, err := omgo.NewForecastRequest(q.Lat, q.Lon)
+	if err != nil {
+		return nil, err
+	}
+
+	req.WithCurrent(
+		omgo.CurrentTemperature2m,
+		omgo.CurrentWeatherCode,
+		omgo.CurrentPrecipitation,
+		omgo.CurrentWindSpeed10m,
+		omgo.CurrentRelativeHumidity2m,
+		omgo.CurrentApparentTemperature,
+	).WithHourly(
+		omgo.HourlyTemperature2m,
+		omgo.HourlyWeatherCode,
+		omgo.HourlyPrecipitation,
+		omgo.HourlyWindSpeed10m,
+		omgo.HourlyRelativeHumidity2m,
+		omgo.HourlyVisibility,
+		omgo.HourlyPrecipitationProbability,
+		omgo.HourlyApparentTemperature,
+	).WithDaily(
+		omgo.DailyTemperature2mMax,
+		omgo.DailyTemperature2mMin,
+		omgo.DailyWeatherCode,
+		omgo.DailyPrecipitationSum,
+		omgo.DailyPrecipitationProbabilityMax,
+		omgo.DailyWindSpeed10mMax,
+		omgo.DailyUVIndexMax,
+		omgo.DailySunrise,
+		omgo.DailySunset,
+		omgo.DailyDaylightDuration,
+	).WithTimezone(timezoneOrAuto(q.Timezone)).
+		WithTemperatureUnit(q.Units.omgoTemperature()).
+		WithPrecipitationUnit(q.Units.omgoPrecipitation()).
+		WithWindSpeedUnit(q.Units.omgoWind())
+	// Servono almeno 3 giorni per coprire la timeline oraria di 48 ore
+	if q.Days > 0 {
+		req.WithForecastDays(max(q.Days, 3))
+	}
+
+	weather, err := p.client.Forecast(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if weather.Hourly == nil || weather.Daily == nil {
+		return nil, fmt.Errorf("risposta open-meteo incompleta")
+	}
+
+	forecast := &Forecast{
+		Provider: p.Name(),
+		Timezone: weather.Timezone,
+	}
+
+	// La visibilità va riportata in metri anche con unità imperiali
+	visibilityScale := 1.0
+	if weather.HourlyUnits != nil && weather.HourlyUnits.Visibility == "ft" {
+		visibilityScale = 0.3048
+	}
+
+	forecast.Hourly = omgoHourly(weather.Hourly, visibilityScale)
+	forecast.HasVisibility = len(weather.Hourly.Visibility) > 0
+
+	// La visibilità non è disponibile nel blocco current: si usa l'ora corrente
+	forecast.Current = forecast.hourlyAt(currentHourIndex(forecast.Hourly, time.Now()))
+	forecast.Current.Time = forecast.Current.Time.In(loadTimezone(weather.Timezone))
+	if c := weather.Current; c != nil {
+		forecast.Current.Time = c.Time
+		forecast.Current.Temperature = deref(c.Temperature2m)
+		forecast.Current.Precipitation = deref(c.Precipitation)
+		forecast.Current.WindSpeed = deref(c.WindSpeed10m)
+		forecast.Current.Humidity = deref(c.RelativeHumidity2m)
+		forecast.Current.ApparentTemperature = deref(c.ApparentTemperature)
+		if c.WeatherCode != nil {
+			forecast.Current.WeatherCode = int(*c.WeatherCode)
+		}
+	}
+
+	forecast.Daily = omgoDaily(weather.Daily)
+
+	if q.Units.Wind == windBeaufort {
+		q.Units.convertWind(forecast)
+	}
+
+	if q.Days > 0 && len(forecast.Daily) > q.Days {
+		forecast.Daily = forecast.Daily[:q.Days]
+	}
+
+	return forecast, nil
+}
+
+// omgoHourly converte la serie oraria di omgo nel modello comune
+func omgoHourly(h *omgo.HourlyData, visibilityScale float64) []HourlyPoint {
+	points := make([]HourlyPoint, 0, len(h.Times))
+	for i, t := range h.Times {
+		points = append(points, HourlyPoint{
+			Time:          t,
+			Temperature:   valueAt(h.Temperature2m, i),
+			WeatherCode:   codeAt(h.WeatherCode, i),
+			Precipitation: valueAt(h.Precipitation, i),
+			WindSpeed:     valueAt(h.WindSpeed10m, i),
+			Humidity:      valueAt(h.RelativeHumidity2m, i),
+			Visibility:    valueAt(h.Visibility, i) * visibilityScale,
+
+			PrecipitationProbability: valueAt(h.PrecipitationProbability, i),
+			ApparentTemperature:      valueAt(h.ApparentTemperature, i),
+		})
+	}
+	return points
+}
+
+// omgoDaily converte la serie giornaliera di omgo nel modello comune
+func omgoDaily(d *omgo.DailyData) []DailyPoint {
+	points := make([]DailyPoint, 0, len(d.Times))
+	for i, t := range d.Times {
+		points = append(points, DailyPoint{
+			Date:                     t,
+			TempMax:                  valueAt(d.Temperature2mMax, i),
+			TempMin:                  valueAt(d.Temperature2mMin, i),
+			WeatherCode:              codeAt(d.WeatherCode, i),
+			PrecipitationSum:         valueAt(d.PrecipitationSum, i),
+			PrecipitationProbability: valueAt(d.PrecipitationProbabilityMax, i),
+			WindMax:                  valueAt(d.WindSpeed10mMax, i),
+			UVIndexMax:               valueAt(d.UVIndexMax, i),
+			Sunrise:                  timeAt(d.Sunrise, i),
+			Sunset:                   timeAt(d.Sunset, i),
+			Daylight:                 time.Duration(valueAt(d.DaylightDuration, i)) * time.Second,
+		})
+	}
+	return points
+}
+
+// timezoneOrAuto restituisce il fuso richiesto, o "auto" se non indicato
+func timezoneOrAuto(name string) string {
+	if name == "" {
+		return autoTimezone
+	}
+	return name
+}
+
+// valueAt restituisce l'elemento i della serie, o zero se assente
+func valueAt(values []float64, i int) float64 {
+	if i < len(values) {
+		return values[i]
+	}
+	return 0
+}
+
+// deref restituisce il valore puntato, o zero se nil
+func deref(value *float64) float64 {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
+
+// timeAt restituisce l'orario i della serie, o zero se assente
+func timeAt(times []time.Time, i int) time.Time {
+	if i < len(times) {
+		return times[i]
+	}
+	return time.Time{}
+}
+
+// codeAt restituisce il codice meteo i della serie, o zero se assente
+func codeAt(codes []omgo.WeatherCode, i int) int {
+	if i < len(codes) {
+		return int(codes[i])
+	}
+	return 0
+}
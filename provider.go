@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+)
+
+// autoTimezone chiede al provider di ricavare il fuso orario dalle coordinate
+const autoTimezone = "auto"
+
+// unknownWeatherCode è il codice meteo di un simbolo senza equivalente WMO;
+// non corrisponde a nessuna condizione e va trattato come sconosciuto
+const unknownWeatherCode = -1
+
+// WeatherProvider è un backend di previsioni meteo
+type WeatherProvider interface {
+	Name() string
+	Forecast(ctx context.Context, q ForecastQuery) (*Forecast, error)
+}
+
+// ForecastQuery descrive la previsione richiesta a un provider
+type ForecastQuery struct {
+	Lat  float64
+	Lon  float64
+	Days int // orizzonte in giorni (1-16)
+
+	// Timezone è un nome IANA oppure autoTimezone
+	Timezone string
+
+	// Units sono le unità in cui restituire temperatura, precipitazioni e vento
+	Units Units
+}
+
+// Forecast è il modello di previsione indipendente dal provider.
+// Tutti gli orari sono espressi nel fuso Timezone della posizione e i valori
+// nelle unità di ForecastQuery.Units (i commenti indicano quelle metriche);
+// la visibilità resta sempre in metri.
+type Forecast struct {
+	Provider string
+	Timezone string // nome IANA
+	Current  HourlyPoint
+	Hourly   []HourlyPoint
+	Daily    []DailyPoint
+
+	// HasVisibility è false se il provider non fornisce la visibilità
+	HasVisibility bool
+}
+
+// HourlyPoint contiene i valori previsti per una singola ora.
+// Per Forecast.Current, Time è il momento dell'osservazione.
+type HourlyPoint struct {
+	Time          time.Time
+	Temperature   float64 // °C
+	WeatherCode   int     // codice WMO o unknownWeatherCode
+	Precipitation float64 // mm
+	WindSpeed     float64 // km/h
+	Humidity      float64 // %
+	Visibility    float64 // metri
+
+	PrecipitationProbability float64 // %
+	ApparentTemperature      float64 // °C
+}
+
+// DailyPoint contiene i valori aggregati di una giornata
+type DailyPoint struct {
+	Date                     time.Time
+	TempMax                  float64 // °C
+	TempMin                  float64 // °C
+	WeatherCode              int     // codice WMO o unknownWeatherCode
+	PrecipitationSum         float64 // mm
+	PrecipitationProbability float64 // %
+	WindMax                  float64 // km/h
+	UVIndexMax               float64
+	Sunrise                  time.Time
+	Sunset                   time.Time
+	Daylight                 time.Duration
+}
+
+// fallbackProvider interroga i provider in ordine finché uno risponde
+type fallbackProvider struct {
+	providers []WeatherProvider
+}
+
+// Name restituisce i nomi dei provider della catena
+func (p *fallbackProvider) Name() string {
+	names := make([]string, len(p.providers))
+	for i, provider := range p.providers {
+		names[i] = provider.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// Forecast restituisce la previsione del primo provider disponibile
+func (p *fallbackProvider) Forecast(ctx context.Context, q ForecastQuery) (*Forecast, error) {
+	var errs []error
+	for _, provider := range p.providers {
+		forecast, err := provider.Forecast(ctx, q)
+		if err == nil {
+			return forecast, nil
+		}
+		log.Printf("⚠️ Provider %s non disponibile: %v", provider.Name(), err)
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// newWeatherProvider costruisce il provider a partire da una lista separata da virgole
+func newWeatherProvider(names string) (WeatherProvider, error) {
+	var providers []WeatherProvider
+	for _, name := range strings.Split(names, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "", "openmeteo", "open-meteo":
+			providers = append(providers, newOpenMeteoProvider(openMeteoURL))
+		case "metno", "met.no":
+			providers = append(providers, newMetNoProvider(metNoURL, metNoUserAgent))
+		default:
+			return nil, fmt.Errorf("provider meteo sconosciuto: %q", name)
+		}
+	}
+	if len(providers) == 1 {
+		return providers[0], nil
+	}
+	return &fallbackProvider{providers: providers}, nil
+}
+
+// hourlyAt restituisce il punto orario all'indice indicato, o un valore vuoto
+func (f *Forecast) hourlyAt(i int) HourlyPoint {
+	if i < 0 || i >= len(f.Hourly) {
+		return HourlyPoint{}
+	}
+	return f.Hourly[i]
+}
+
+// dailyAt restituisce il giorno all'indice indicato, o un valore vuoto
+func (f *Forecast) dailyAt(i int) DailyPoint {
+	if i < 0 || i >= len(f.Daily) {
+		return DailyPoint{}
+	}
+	return f.Daily[i]
+}
+
+// currentHourIndex restituisce l'indice dell'ora che contiene now
+func currentHourIndex(hourly []HourlyPoint, now time.Time) int {
+	index := 0
+	for i, point := range hourly {
+		if point.Time.After(now) {
+			break
+		}
+		index = i
+	}
+	return index
+}
+
+// loadTimezone carica un fuso orario IANA o fisso ("UTC+2"), con UTC come ripiego
+func loadTimezone(name string) *time.Location {
+	if name == "" || name == autoTimezone {
+		return time.UTC
+	}
+	if loc, err := time.LoadLocation(name); err == nil {
+		return loc
+	}
+	var offset int
+	if _, err := fmt.Sscanf(name, "UTC%d", &offset); err == nil {
+		return time.FixedZone(name, offset*3600)
+	}
+	return time.UTC
+}
+
+// longitudeTimezone stima un fuso orario fisso dalla longitudine,
+// per i provider che non sanno ricavarlo dalle coordinate
+func longitudeTimezone(lon float64) *time.Location {
+	offset := int(math.Round(lon / 15))
+	if offset == 0 {
+		return time.UTC
+	}
+	return time.FixedZone(fmt.Sprintf("UTC%+d", offset), offset*3600)
+}
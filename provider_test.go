@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// metNoFixture è una risposta locationforecast in UTC a cavallo della
+// mezzanotte di Roma (UTC+2 in estate), più una voce solo a 6 ore
+const metNoFixture = `{"properties":{"timeseries":[
+ {"time":"2026-07-01T20:00:00Z","data":{"instant":{"details":{"air_temperature":20,"relative_humidity":60,"wind_speed":10,"ultraviolet_index_clear_sky":0.5}},
+  "next_1_hours":{"summary":{"symbol_code":"rain"},"details":{"precipitation_amount":0.5,"probability_of_precipitation":70}}}},
+ {"time":"2026-07-01T21:00:00Z","data":{"instant":{"details":{"air_temperature":18,"relative_humidity":65,"wind_speed":5,"ultraviolet_index_clear_sky":0}},
+  "next_1_hours":{"summary":{"symbol_code":"lightrain"},"details":{"precipitation_amount":1.0,"probability_of_precipitation":90}}}},
+ {"time":"2026-07-01T22:00:00Z","data":{"instant":{"details":{"air_temperature":15,"relative_humidity":80,"wind_speed":2,"ultraviolet_index_clear_sky":0}},
+  "next_1_hours":{"summary":{"symbol_code":"heavyrainshowers_night"},"details":{"precipitation_amount":0.2,"probability_of_precipitation":40}}}},
+ {"time":"2026-07-01T23:00:00Z","data":{"instant":{"details":{"air_temperature":17,"relative_humidity":75,"wind_speed":3,"ultraviolet_index_clear_sky":0}},
+  "next_1_hours":{"summary":{"symbol_code":"clearsky_night"},"details":{"precipitation_amount":0,"probability_of_precipitation":10}}}},
+ {"time":"2026-07-03T00:00:00Z","data":{"instant":{"details":{"air_temperature":12,"relative_humidity":70,"wind_speed":1,"ultraviolet_index_clear_sky":0}},
+  "next_6_hours":{"summary":{"symbol_code":"fog"},"details":{"precipitation_amount":3,"probability_of_precipitation":20}}}}
+]}}`
+
+// openMeteoFixture è una risposta forecast con orari locali di Roma
+const openMeteoFixture = `{
+ "latitude":41.9,"longitude":12.5,"timezone":"Europe/Rome","utc_offset_seconds":7200,
+ "current":{"time":"2026-07-01T10:15","interval":900,"temperature_2m":24.5,"relative_humidity_2m":55,
+  "apparent_temperature":25.1,"precipitation":0.1,"weather_code":3,"wind_speed_10m":12},
+ "hourly_units":{"visibility":"m"},
+ "hourly":{"time":["2026-07-01T10:00","2026-07-01T11:00"],
+  "temperature_2m":[24,25],"weather_code":[3,61],"precipitation":[0,0.4],"wind_speed_10m":[11,13],
+  "relative_humidity_2m":[55,60],"visibility":[24000,18000],"precipitation_probability":[10,60],
+  "apparent_temperature":[24.8,25.5]},
+ "daily":{"time":["2026-07-01","2026-07-02","2026-07-03","2026-07-04"],
+  "temperature_2m_max":[28,29,30,31],"temperature_2m_min":[18,19,20,21],"weather_code":[61,3,0,1],
+  "precipitation_sum":[2.5,0,0,0],"precipitation_probability_max":[80,10,0,5],"wind_speed_10m_max":[20,15,10,12],
+  "uv_index_max":[7.5,8,8.5,9],"sunrise":["2026-07-01T05:37","2026-07-02T05:38","2026-07-03T05:38","2026-07-04T05:39"],
+  "sunset":["2026-07-01T20:49","2026-07-02T20:49","2026-07-03T20:49","2026-07-04T20:48"],
+  "daylight_duration":[54720,54660,54660,54540]}
+}`
+
+// newFixtureServer restituisce uno stand-in che risponde sempre con body
+func newFixtureServer(t *testing.T, body string, check func(*http.Request)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if check != nil {
+			check(r)
+		}
+		w.Header().Set(contentTypeHeader, contentTypeJSON)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newStatusServer restituisce uno stand-in che risponde sempre con status
+func newStatusServer(t *testing.T, status int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, http.StatusText(status), status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestMetNoSymbolToWMO(t *testing.T) {
+	tests := []struct {
+		symbol string
+		want   int
+	}{
+		{"clearsky_day", 0},
+		{"clearsky_night", 0},
+		{"fair_polartwilight", 1},
+		{"partlycloudy_day", 2},
+		{"cloudy", 3},
+		{"fog", 45},
+		{"lightrain", 61},
+		{"rain", 63},
+		{"heavyrain", 65},
+		{"rainshowers_day", 81},
+		{"heavyrainshowers_night", 82},
+		{"sleet", 73},
+		{"snow", 73},
+		{"heavysnowshowers_day", 86},
+		{"rainandthunder", 95},
+		{"heavysnowshowersandthunder_night", 95},
+		{"unknown", unknownWeatherCode},
+		{"", unknownWeatherCode},
+	}
+	for _, tt := range tests {
+		if got := metNoSymbolToWMO(tt.symbol); got != tt.want {
+			t.Errorf("metNoSymbolToWMO(%q) = %d, want %d", tt.symbol, got, tt.want)
+		}
+	}
+}
+
+func TestMetNoForecast(t *testing.T) {
+	srv := newFixtureServer(t, metNoFixture, func(r *http.Request) {
+		if got := r.Header.Get("User-Agent"); got != "test-agent" {
+			t.Errorf("User-Agent = %q, want test-agent", got)
+		}
+		if got := r.URL.RawQuery; got != "lat=41.9028&lon=12.4964" {
+			t.Errorf("query = %q, want 4 decimals", got)
+		}
+	})
+
+	p := newMetNoProvider(srv.URL, "test-agent")
+	forecast, err := p.Forecast(context.Background(), ForecastQuery{
+		Lat: 41.902783, Lon: 12.496366, Days: 2,
+		Timezone: "Europe/Rome",
+		Units:    Units{}.normalize(),
+	})
+	if err != nil {
+		t.Fatalf("Forecast: %v", err)
+	}
+
+	if forecast.Provider != "metno" || forecast.Timezone != "Europe/Rome" {
+		t.Errorf("provider/timezone = %s/%s", forecast.Provider, forecast.Timezone)
+	}
+	if forecast.HasVisibility {
+		t.Error("met.no non fornisce la visibilità, HasVisibility deve essere false")
+	}
+
+	// Solo le voci con next_1_hours entrano nella serie oraria, in ora locale
+	if len(forecast.Hourly) != 4 {
+		t.Fatalf("len(Hourly) = %d, want 4", len(forecast.Hourly))
+	}
+	first := forecast.Hourly[0]
+	if first.Time.Location().String() != "Europe/Rome" || first.Time.Hour() != 22 {
+		t.Errorf("Hourly[0].Time = %v, want 22:00 Europe/Rome", first.Time)
+	}
+	if first.WeatherCode != 63 || first.WindSpeed != 36 || first.Precipitation != 0.5 {
+		t.Errorf("Hourly[0] = %+v", first)
+	}
+	if got := forecast.Hourly[2].Time.Format("2006-01-02 15:04"); got != "2026-07-02 00:00" {
+		t.Errorf("Hourly[2].Time = %s, want 2026-07-02 00:00", got)
+	}
+
+	// I giorni seguono la mezzanotte locale e sono limitati a Days
+	if len(forecast.Daily) != 2 {
+		t.Fatalf("len(Daily) = %d, want 2", len(forecast.Daily))
+	}
+	tests := []struct {
+		date          string
+		max, min, sum float64
+		code          int
+		probability   float64
+	}{
+		{"2026-07-01", 20, 18, 1.5, 63, 90},
+		{"2026-07-02", 17, 15, 0.2, 82, 40},
+	}
+	for i, tt := range tests {
+		day := forecast.Daily[i]
+		if got := day.Date.Format("2006-01-02"); got != tt.date {
+			t.Errorf("Daily[%d].Date = %s, want %s", i, got, tt.date)
+		}
+		if day.TempMax != tt.max || day.TempMin != tt.min {
+			t.Errorf("Daily[%d] max/min = %v/%v, want %v/%v", i, day.TempMax, day.TempMin, tt.max, tt.min)
+		}
+		if diff := day.PrecipitationSum - tt.sum; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("Daily[%d].PrecipitationSum = %v, want %v", i, day.PrecipitationSum, tt.sum)
+		}
+		if day.WeatherCode != tt.code || day.PrecipitationProbability != tt.probability {
+			t.Errorf("Daily[%d] code/probability = %d/%v, want %d/%v",
+				i, day.WeatherCode, day.PrecipitationProbability, tt.code, tt.probability)
+		}
+	}
+	if forecast.Daily[0].UVIndexMax != 0.5 || forecast.Daily[0].WindMax != 36 {
+		t.Errorf("Daily[0] uv/wind = %v/%v", forecast.Daily[0].UVIndexMax, forecast.Daily[0].WindMax)
+	}
+}
+
+func TestMetNoForecastKeepsHourlyBeyondDays(t *testing.T) {
+	// 72 voci orarie da mezzanotte a Roma (22:00 UTC), cioè tre giorni locali
+	start := time.Date(2026, 6, 30, 22, 0, 0, 0, time.UTC)
+	entries := make([]string, 72)
+	for i := range entries {
+		entries[i] = fmt.Sprintf(`{"time":%q,"data":{"instant":{"details":{"air_temperature":%d}},
+			"next_1_hours":{"summary":{"symbol_code":"cloudy"},"details":{"precipitation_amount":0}}}}`,
+			start.Add(time.Duration(i)*time.Hour).Format(time.RFC3339), i)
+	}
+	srv := newFixtureServer(t, `{"properties":{"timeseries":[`+strings.Join(entries, ",")+`]}}`, nil)
+
+	p := newMetNoProvider(srv.URL, "")
+	forecast, err := p.Forecast(context.Background(), ForecastQuery{
+		Lat: 41.9, Lon: 12.5, Days: 1, Timezone: "Europe/Rome", Units: Units{}.normalize(),
+	})
+	if err != nil {
+		t.Fatalf("Forecast: %v", err)
+	}
+	if len(forecast.Hourly) != 72 {
+		t.Errorf("len(Hourly) = %d, want 72", len(forecast.Hourly))
+	}
+	if len(forecast.Daily) != 1 || forecast.Daily[0].TempMax != 23 {
+		t.Errorf("Daily = %+v, want only the first day with max 23", forecast.Daily)
+	}
+}
+
+func TestMetNoForecastImperial(t *testing.T) {
+	srv := newFixtureServer(t, metNoFixture, nil)
+
+	p := newMetNoProvider(srv.URL, "")
+	forecast, err := p.Forecast(context.Background(), ForecastQuery{
+		Lat: 41.9, Lon: 12.5, Days: 1,
+		Timezone: "Europe/Rome",
+		Units:    Units{System: unitsImperial}.normalize(),
+	})
+	if err != nil {
+		t.Fatalf("Forecast: %v", err)
+	}
+	day := forecast.Daily[0]
+	if day.TempMax != 68 || day.TempMin != 64.4 {
+		t.Errorf("max/min = %v/%v, want 68/64.4 °F", day.TempMax, day.TempMin)
+	}
+	if got := forecast.Hourly[0].WindSpeed; got < 22.36 || got > 22.37 {
+		t.Errorf("wind = %v, want ~22.37 mph", got)
+	}
+}
+
+func TestMetNoForecastAutoTimezone(t *testing.T) {
+	srv := newFixtureServer(t, metNoFixture, nil)
+
+	// Senza fuso esplicito si stima un offset fisso dalla longitudine
+	p := newMetNoProvider(srv.URL, "")
+	forecast, err := p.Forecast(context.Background(), ForecastQuery{
+		Lat: 35.7, Lon: 139.7, Timezone: autoTimezone, Units: Units{}.normalize(),
+	})
+	if err != nil {
+		t.Fatalf("Forecast: %v", err)
+	}
+	if forecast.Timezone != "UTC+9" {
+		t.Errorf("Timezone = %s, want UTC+9", forecast.Timezone)
+	}
+	if got := forecast.Hourly[0].Time.Hour(); got != 5 {
+		t.Errorf("Hourly[0] hour = %d, want 5", got)
+	}
+}
+
+func TestMetNoForecastErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		srv  *httptest.Server
+	}{
+		{"status", newStatusServer(t, http.StatusServiceUnavailable)},
+		{"empty", newFixtureServer(t, `{"properties":{"timeseries":[]}}`, nil)},
+		{"invalid", newFixtureServer(t, `{`, nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newMetNoProvider(tt.srv.URL, "")
+			if _, err := p.Forecast(context.Background(), ForecastQuery{Lat: 1, Lon: 1}); err == nil {
+				t.Error("Forecast: expected error")
+			}
+		})
+	}
+}
+
+func TestOpenMeteoForecast(t *testing.T) {
+	srv := newFixtureServer(t, openMeteoFixture, func(r *http.Request) {
+		q := r.URL.Query()
+		if got := q.Get("timezone"); got != "Europe/Rome" {
+			t.Errorf("timezone = %q, want Europe/Rome", got)
+		}
+		if got := q.Get("forecast_days"); got != "3" {
+			t.Errorf("forecast_days = %q, want 3 (minimo per la timeline)", got)
+		}
+		if !strings.Contains(q.Get("hourly"), "visibility") {
+			t.Errorf("hourly = %q, want visibility", q.Get("hourly"))
+		}
+	})
+
+	p := newOpenMeteoProvider(srv.URL)
+	forecast, err := p.Forecast(context.Background(), ForecastQuery{
+		Lat: 41.9, Lon: 12.5, Days: 2,
+		Timezone: "Europe/Rome",
+		Units:    Units{}.normalize(),
+	})
+	if err != nil {
+		t.Fatalf("Forecast: %v", err)
+	}
+
+	if forecast.Provider != "openmeteo" || forecast.Timezone != "Europe/Rome" {
+		t.Errorf("provider/timezone = %s/%s", forecast.Provider, forecast.Timezone)
+	}
+	if !forecast.HasVisibility {
+		t.Error("HasVisibility = false, want true")
+	}
+
+	current := forecast.Current
+	if current.Temperature != 24.5 || current.ApparentTemperature != 25.1 || current.WeatherCode != 3 {
+		t.Errorf("Current = %+v", current)
+	}
+	if got := current.Time.Format("2006-01-02 15:04 MST"); got != "2026-07-01 10:15 CEST" {
+		t.Errorf("Current.Time = %s", got)
+	}
+
+	if len(forecast.Hourly) != 2 || forecast.Hourly[1].Visibility != 18000 || forecast.Hourly[1].WeatherCode != 61 {
+		t.Errorf("Hourly = %+v", forecast.Hourly)
+	}
+
+	// I giorni oltre Days vengono scartati
+	if len(forecast.Daily) != 2 {
+		t.Fatalf("len(Daily) = %d, want 2", len(forecast.Daily))
+	}
+	day := forecast.Daily[0]
+	if day.TempMax != 28 || day.TempMin != 18 || day.PrecipitationSum != 2.5 || day.UVIndexMax != 7.5 {
+		t.Errorf("Daily[0] = %+v", day)
+	}
+	if day.Daylight != 15*time.Hour+12*time.Minute || day.Sunrise.Format("15:04") != "05:37" {
+		t.Errorf("Daily[0] daylight/sunrise = %v/%v", day.Daylight, day.Sunrise)
+	}
+}
+
+func TestOpenMeteoForecastBeaufort(t *testing.T) {
+	srv := newFixtureServer(t, openMeteoFixture, func(r *http.Request) {
+		// Open-Meteo non supporta Beaufort: si chiede km/h
+		if got := r.URL.Query().Get("wind_speed_unit"); got != "kmh" {
+			t.Errorf("wind_speed_unit = %q, want kmh", got)
+		}
+	})
+
+	p := newOpenMeteoProvider(srv.URL)
+	forecast, err := p.Forecast(context.Background(), ForecastQuery{
+		Lat: 41.9, Lon: 12.5, Units: Units{Wind: windBeaufort}.normalize(),
+	})
+	if err != nil {
+		t.Fatalf("Forecast: %v", err)
+	}
+	if forecast.Current.WindSpeed != 3 || forecast.Daily[0].WindMax != 4 {
+		t.Errorf("wind = %v/%v Bft, want 3/4", forecast.Current.WindSpeed, forecast.Daily[0].WindMax)
+	}
+}
+
+func TestCurrentHourIndex(t *testing.T) {
+	base := time.Date(2026, 7, 1, 10, 0, 0, 0, time.UTC)
+	hourly := []HourlyPoint{{Time: base}, {Time: base.Add(time.Hour)}, {Time: base.Add(2 * time.Hour)}}
+	tests := []struct {
+		now  time.Time
+		want int
+	}{
+		{base.Add(-time.Hour), 0},
+		{base.Add(30 * time.Minute), 0},
+		{base.Add(time.Hour), 1},
+		{base.Add(5 * time.Hour), 2},
+	}
+	for _, tt := range tests {
+		if got := currentHourIndex(hourly, tt.now); got != tt.want {
+			t.Errorf("currentHourIndex(%v) = %d, want %d", tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestFallbackProvider(t *testing.T) {
+	failing := newStatusServer(t, http.StatusServiceUnavailable)
+	metNo := newFixtureServer(t, metNoFixture, nil)
+
+	p := &fallbackProvider{providers: []WeatherProvider{
+		newOpenMeteoProvider(failing.URL),
+		newMetNoProvider(metNo.URL, ""),
+	}}
+	if got := p.Name(); got != "openmeteo,metno" {
+		t.Errorf("Name = %q", got)
+	}
+
+	forecast, err := p.Forecast(context.Background(), ForecastQuery{
+		Lat: 41.9, Lon: 12.5, Days: 2, Timezone: "Europe/Rome", Units: Units{}.normalize(),
+	})
+	if err != nil {
+		t.Fatalf("Forecast: %v", err)
+	}
+	if forecast.Provider != "metno" {
+		t.Errorf("Provider = %s, want metno", forecast.Provider)
+	}
+}
+
+func TestFallbackProviderAllFailing(t *testing.T) {
+	first := newStatusServer(t, http.StatusInternalServerError)
+	second := newStatusServer(t, http.StatusBadGateway)
+
+	p := &fallbackProvider{providers: []WeatherProvider{
+		newOpenMeteoProvider(first.URL),
+		newMetNoProvider(second.URL, ""),
+	}}
+	_, err := p.Forecast(context.Background(), ForecastQuery{Lat: 41.9, Lon: 12.5})
+	if err == nil {
+		t.Fatal("Forecast: expected error")
+	}
+	for _, name := range []string{"openmeteo", "metno"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error %q does not mention %s", err, name)
+		}
+	}
+}
+
+func TestNewWeatherProvider(t *testing.T) {
+	p, err := newWeatherProvider("openmeteo, metno")
+	if err != nil {
+		t.Fatalf("newWeatherProvider: %v", err)
+	}
+	if _, ok := p.(*fallbackProvider); !ok || p.Name() != "openmeteo,metno" {
+		t.Errorf("provider = %T %s", p, p.Name())
+	}
+
+	if p, err := newWeatherProvider("met.no"); err != nil || p.Name() != "metno" {
+		t.Errorf("met.no: %v %v", p, err)
+	}
+	if _, err := newWeatherProvider("accuweather"); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// settingsMutex serializza i salvataggi, così un'istantanea più vecchia
+// non può sovrascrivere una più recente
+var settingsMutex sync.Mutex
+
+// Settings contiene le impostazioni modificabili dall'interfaccia,
+// salvate su file per sopravvivere ai riavvii
+type Settings struct {
+	IntervalMinutes        int             `json:"interval_minutes"`
+	StartHour              int             `json:"start_hour"`
+	EndHour                int             `json:"end_hour"`
+	Units                  Units           `json:"units"`
+	NotificationsEnabled   bool            `json:"notifications_enabled"`
+	Locations              []SavedLocation `json:"locations"`
+	NextLocationID         int             `json:"next_location_id"`
+	ActiveLocationID       string          `json:"active_location_id"`
+	NotificationLocationID string          `json:"notification_location_id"`
+	AlertRules             []AlertRule     `json:"alert_rules"`
+	NextAlertRuleID        int             `json:"next_alert_rule_id"`
+}
+
+// loadSettings applica le impostazioni salvate sopra quelle di .env e indica
+// se le notifiche vanno attivate; senza file restano attive come di default
+func loadSettings() (notificationsOn bool, err error) {
+	data, err := os.ReadFile(settingsPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return true, err
+	}
+
+	var s Settings
+	if err := json.Unmarshal(data, &s); err != nil {
+		return true, err
+	}
+
+	configMutex.Lock()
+	if s.IntervalMinutes > 0 {
+		notificationInterval = time.Duration(s.IntervalMinutes) * time.Minute
+	}
+	if s.StartHour >= 0 && s.StartHour <= 23 {
+		notificationStartHour = s.StartHour
+	}
+	if s.EndHour >= 0 && s.EndHour <= 23 {
+		notificationEndHour = s.EndHour
+	}
+	units = s.Units.normalize()
+	configMutex.Unlock()
+
+	locationMutex.Lock()
+	savedLocations = savedLocations[:0]
+	for _, l := range s.Locations {
+		if err := l.validate(); err != nil {
+			log.Printf("⚠️ Posizione salvata %q ignorata: %v", l.ID, err)
+			continue
+		}
+		savedLocations = append(savedLocations, l)
+	}
+	nextLocationID = s.NextLocationID
+	activeLocationID = ""
+	if _, ok := findLocation(s.ActiveLocationID); ok {
+		activeLocationID = s.ActiveLocationID
+	}
+	notificationLocationID = ""
+	if _, ok := findLocation(s.NotificationLocationID); ok {
+		notificationLocationID = s.NotificationLocationID
+	}
+	count := len(savedLocations)
+	locationMutex.Unlock()
+
+	alertMutex.Lock()
+	alertRules = alertRules[:0]
+	for _, r := range s.AlertRules {
+		if err := r.validate(); err != nil {
+			log.Printf("⚠️ Regola di avviso %q ignorata: %v", r.ID, err)
+			continue
+		}
+		alertRules = append(alertRules, r)
+	}
+	nextAlertRuleID = s.NextAlertRuleID
+	rules := len(alertRules)
+	alertMutex.Unlock()
+
+	log.Printf("💾 Impostazioni caricate da %s (%d posizioni salvate, %d regole di avviso)", settingsPath, count, rules)
+	return s.NotificationsEnabled, nil
+}
+
+// saveSettings scrive in modo atomico le impostazioni correnti; un errore
+// viene solo registrato, perché la modifica resta comunque attiva in memoria
+func saveSettings() {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+
+	var s Settings
+
+	configMutex.RLock()
+	s.IntervalMinutes = int(notificationInterval / time.Minute)
+	s.StartHour = notificationStartHour
+	s.EndHour = notificationEndHour
+	s.Units = units
+	configMutex.RUnlock()
+
+	notificationsMutex.RLock()
+	s.NotificationsEnabled = notificationsEnabled
+	notificationsMutex.RUnlock()
+
+	locationMutex.RLock()
+	s.Locations = append([]SavedLocation{}, savedLocations...)
+	s.NextLocationID = nextLocationID
+	s.ActiveLocationID = activeLocationID
+	s.NotificationLocationID = notificationLocationID
+	locationMutex.RUnlock()
+
+	alertMutex.RLock()
+	s.AlertRules = append([]AlertRule{}, alertRules...)
+	s.NextAlertRuleID = nextAlertRuleID
+	alertMutex.RUnlock()
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(settingsPath, data)
+	}
+	if err != nil {
+		log.Printf("⚠️ Impostazioni non salvate: %v", err)
+	}
+}
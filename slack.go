@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// slackTextMax è la lunghezza massima del testo di un blocco section
+const slackTextMax = 3000
+
+// slackNotifier invia le notifiche a Slack tramite incoming webhook,
+// con il riepilogo impaginato in Block Kit
+type slackNotifier struct {
+	webhookURL string
+	channel    string
+	username   string
+}
+
+// newSlackNotifierFromEnv crea il canale Slack dalle variabili SLACK_*
+func newSlackNotifierFromEnv() (*slackNotifier, error) {
+	s := &slackNotifier{
+		webhookURL: os.Getenv("SLACK_WEBHOOK_URL"),
+		channel:    os.Getenv("SLACK_CHANNEL"),
+		username:   os.Getenv("SLACK_USERNAME"),
+	}
+	if s.webhookURL == "" {
+		return nil, fmt.Errorf("SLACK_WEBHOOK_URL non impostato")
+	}
+	return s, nil
+}
+
+// Name restituisce il nome del canale
+func (s *slackNotifier) Name() string {
+	return "slack"
+}
+
+// slackText è un oggetto di testo di Block Kit
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// slackBlock è un blocco di Block Kit (header, context, section, divider)
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+// slackEscape rende sicuro il testo per il formato mrkdwn
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// Notify invia il riepilogo meteo: intestazione, contesto e una sezione per blocco
+func (s *slackNotifier) Notify(ctx context.Context, n Notification) error {
+	summary := n.Summary
+
+	blocks := []slackBlock{
+		{Type: "header", Text: &slackText{Type: "plain_text", Text: truncateRunes(summary.Title, 150)}},
+		{Type: "context", Elements: []slackText{{Type: "mrkdwn", Text: slackEscape(summary.Subtitle)}}},
+		{Type: "divider"},
+	}
+	for _, section := range summary.Sections {
+		text := "*" + slackEscape(section.Title) + "*\n" + slackEscape(strings.Join(section.Lines, "\n"))
+		blocks = append(blocks, slackBlock{
+			Type: "section",
+			Text: &slackText{Type: "mrkdwn", Text: truncateRunes(text, slackTextMax)},
+		})
+	}
+
+	// text è il riepilogo mostrato nelle notifiche push e dai client senza blocchi
+	payload := map[string]interface{}{
+		"text":   slackEscape(summary.Text()),
+		"blocks": blocks,
+	}
+	if s.channel != "" {
+		payload["channel"] = s.channel
+	}
+	if s.username != "" {
+		payload["username"] = s.username
+	}
+	return postJSON(ctx, s.webhookURL, nil, payload)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// slackPayload è il corpo inviato all'incoming webhook di Slack
+type slackPayload struct {
+	Text     string       `json:"text"`
+	Channel  string       `json:"channel"`
+	Username string       `json:"username"`
+	Blocks   []slackBlock `json:"blocks"`
+}
+
+func TestSlackNotify(t *testing.T) {
+	var payload slackPayload
+	s := &slackNotifier{webhookURL: capturePayload(t, &payload), channel: "#meteo", username: "Meteo"}
+
+	n := testNotification()
+	n.Summary.Subtitle = "Roma <centro> & dintorni"
+	n.Summary.Sections[1] = summarySection{Title: "Allerta <rossa>", Lines: []string{"Vento > 60 km/h & raffiche", "<!channel>"}}
+	if err := s.Notify(context.Background(), n); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	if payload.Channel != "#meteo" || payload.Username != "Meteo" {
+		t.Errorf("payload = %+v", payload)
+	}
+
+	var types []string
+	for _, b := range payload.Blocks {
+		types = append(types, b.Type)
+	}
+	if got := strings.Join(types, ","); got != "header,context,divider,section,section" {
+		t.Fatalf("blocks = %s", got)
+	}
+
+	header := payload.Blocks[0]
+	if header.Text == nil || header.Text.Type != "plain_text" || header.Text.Text != "🌤️ Meteo Roma" {
+		t.Errorf("header = %+v", header.Text)
+	}
+	subtitle := payload.Blocks[1].Elements
+	if len(subtitle) != 1 || subtitle[0].Type != "mrkdwn" || subtitle[0].Text != "Roma &lt;centro&gt; &amp; dintorni" {
+		t.Errorf("context = %+v", subtitle)
+	}
+
+	first := payload.Blocks[3].Text
+	if first == nil || first.Type != "mrkdwn" || !strings.HasPrefix(first.Text, "*Condizioni Attuali*\n🌦️ Pioggia leggera\n💧 Umidità: 55%\n") {
+		t.Errorf("section 0 = %+v", first)
+	}
+
+	// I caratteri di controllo di mrkdwn non devono produrre link o menzioni
+	want := "*Allerta &lt;rossa&gt;*\nVento &gt; 60 km/h &amp; raffiche\n&lt;!channel&gt;"
+	if got := payload.Blocks[4].Text.Text; got != want {
+		t.Errorf("section 1 = %q, want %q", got, want)
+	}
+	if strings.Contains(payload.Text, "<") || !strings.Contains(payload.Text, "&lt;!channel&gt;") {
+		t.Errorf("text = %q", payload.Text)
+	}
+}
+
+func TestSlackNotifyTruncates(t *testing.T) {
+	var payload slackPayload
+	s := &slackNotifier{webhookURL: capturePayload(t, &payload)}
+
+	n := testNotification()
+	n.Summary.Title = strings.Repeat("è", 200)
+	n.Summary.Sections = []summarySection{{Title: "Lunga", Lines: []string{strings.Repeat("à", slackTextMax)}}}
+	if err := s.Notify(context.Background(), n); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	if got := utf8.RuneCountInString(payload.Blocks[0].Text.Text); got != 150 {
+		t.Errorf("header: %d caratteri, want 150", got)
+	}
+	section := payload.Blocks[3].Text.Text
+	if got := utf8.RuneCountInString(section); got != slackTextMax || !strings.HasSuffix(section, "…") {
+		t.Errorf("section: %d caratteri, want %d terminati da …", got, slackTextMax)
+	}
+
+	// Canale e nome sono facoltativi
+	if payload.Channel != "" || payload.Username != "" {
+		t.Errorf("payload = %+v", payload)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// sunTimes calcola alba e tramonto per la data indicata con l'equazione
+// dell'alba (precisione di circa un minuto). ok è false durante il giorno
+// o la notte polare, quando il sole non sorge o non tramonta.
+func sunTimes(date time.Time, lat, lon float64) (sunrise, sunset time.Time, ok bool) {
+	const j2000 = 2451545.0
+	rad := math.Pi / 180
+
+	midnight := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	julianDate := float64(midnight.Unix())/86400 + 2440587.5
+	n := math.Ceil(julianDate - j2000 + 0.0008)
+
+	// Mezzogiorno solare medio, anomalia media e longitudine eclittica
+	meanNoon := n - lon/360
+	anomaly := math.Mod(357.5291+0.98560028*meanNoon, 360)
+	center := 1.9148*math.Sin(anomaly*rad) + 0.02*math.Sin(2*anomaly*rad) + 0.0003*math.Sin(3*anomaly*rad)
+	longitude := math.Mod(anomaly+center+180+102.9372, 360)
+	transit := j2000 + meanNoon + 0.0053*math.Sin(anomaly*rad) - 0.0069*math.Sin(2*longitude*rad)
+
+	declination := math.Asin(math.Sin(longitude*rad) * math.Sin(23.4397*rad))
+	cosHourAngle := (math.Sin(-0.833*rad) - math.Sin(lat*rad)*math.Sin(declination)) /
+		(math.Cos(lat*rad) * math.Cos(declination))
+	if cosHourAngle < -1 || cosHourAngle > 1 {
+		return time.Time{}, time.Time{}, false
+	}
+	hourAngle := math.Acos(cosHourAngle) / rad
+
+	toTime := func(julian float64) time.Time {
+		seconds := (julian - 2440587.5) * 86400
+		return time.Unix(int64(math.Round(seconds)), 0).In(date.Location())
+	}
+	return toTime(transit - hourAngle/360), toTime(transit + hourAngle/360), true
+}
@@ -1,62 +1,56 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
+	"strings"
 )
 
-// sendTelegramNotification invia una notifica meteo su Telegram
-func sendTelegramNotification(data *WeatherData) error {
-	if telegramBotToken == "" || telegramChatID == "" {
-		return fmt.Errorf("telegram non configurato")
-	}
+// telegramAPIURL è l'endpoint della Bot API di Telegram
+const telegramAPIURL = "https://api.telegram.org"
 
-	message := fmt.Sprintf(
-		"🌤️ *Meteo %s*\n\n"+
-			"🕐 %s\n\n"+
-			"*Condizioni Attuali*\n"+
-			"%s\n"+
-			"🌡️ Temperatura: %.1f°C\n"+
-			"💧 Umidità: %.0f%%\n"+
-			"💨 Vento: %.1f km/h\n"+
-			"🌧️ Precipitazioni: %.1f mm\n\n"+
-			"*Oggi*\n"+
-			"Max: %.1f°C | Min: %.1f°C",
-		data.City,
-		data.Time,
-		data.CurrentCondition,
-		data.CurrentTemp,
-		data.Humidity,
-		data.WindSpeed,
-		data.Precipitation,
-		data.TodayMax,
-		data.TodayMin,
-	)
-
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", telegramBotToken)
+// telegramNotifier invia le notifiche a una chat tramite un bot Telegram
+type telegramNotifier struct {
+	apiURL string
+	token  string
+	chatID string
+}
 
-	payload := map[string]interface{}{
-		"chat_id":    telegramChatID,
-		"text":       message,
-		"parse_mode": "Markdown",
+// newTelegramNotifier crea il canale Telegram, che richiede token e chat
+func newTelegramNotifier(token, chatID string) (*telegramNotifier, error) {
+	if token == "" || chatID == "" {
+		return nil, fmt.Errorf("telegram non configurato")
 	}
+	return &telegramNotifier{apiURL: telegramAPIURL, token: token, chatID: chatID}, nil
+}
 
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return err
-	}
+// Name restituisce il nome del canale
+func (t *telegramNotifier) Name() string {
+	return "telegram"
+}
 
-	resp, err := http.Post(url, contentTypeJSON, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return err
+// Notify invia il riepilogo meteo in Markdown
+func (t *telegramNotifier) Notify(ctx context.Context, n Notification) error {
+	summary := n.Summary
+
+	var message strings.Builder
+	fmt.Fprintf(&message, "*%s*\n\n%s", summary.Title, summary.Subtitle)
+	for _, section := range summary.Sections {
+		fmt.Fprintf(&message, "\n\n*%s*", section.Title)
+		for _, line := range section.Lines {
+			message.WriteString("\n" + line)
+		}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("telegram API status %d", resp.StatusCode)
+	payload := map[string]interface{}{
+		"chat_id":    t.chatID,
+		"text":       message.String(),
+		"parse_mode": "Markdown",
 	}
 
+	url := fmt.Sprintf("%s/bot%s/sendMessage", t.apiURL, t.token)
+	if err := postJSON(ctx, url, nil, payload); err != nil {
+		return fmt.Errorf("telegram API %w", err)
+	}
 	return nil
 }
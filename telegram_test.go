@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTelegramNotify(t *testing.T) {
+	var payload map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/botTOKEN/sendMessage" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get(contentTypeHeader); got != contentTypeJSON {
+			t.Errorf("Content-Type = %q", got)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	tg, err := newTelegramNotifier("TOKEN", "42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tg.apiURL = srv.URL
+	if err := tg.Notify(context.Background(), testNotification()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	if payload["chat_id"] != "42" || payload["parse_mode"] != "Markdown" {
+		t.Errorf("payload = %v", payload)
+	}
+	want := "*🌤️ Meteo Roma*\n\n🕐 10:15 - 01/07/2026 (Europe/Rome)\n\n*Condizioni Attuali*\n🌦️ Pioggia leggera"
+	if !strings.HasPrefix(payload["text"], want) {
+		t.Errorf("text = %q, want prefix %q", payload["text"], want)
+	}
+	if !strings.HasSuffix(payload["text"], "*Oggi*\nMax: 28.0°C | Min: 18.0°C") {
+		t.Errorf("text = %q", payload["text"])
+	}
+}
+
+func TestTelegramNotifyError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"ok":false,"description":"Bad Request: chat not found"}`, http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	tg := &telegramNotifier{apiURL: srv.URL, token: "TOKEN", chatID: "0"}
+	err := tg.Notify(context.Background(), testNotification())
+	if err == nil || !strings.Contains(err.Error(), "status 400") || !strings.Contains(err.Error(), "chat not found") {
+		t.Errorf("Notify = %v, want status 400 with description", err)
+	}
+}
+
+func TestNewTelegramNotifierRequiresConfig(t *testing.T) {
+	for _, c := range [][2]string{{"", "42"}, {"TOKEN", ""}} {
+		if _, err := newTelegramNotifier(c[0], c[1]); err == nil {
+			t.Errorf("newTelegramNotifier(%q, %q): expected error", c[0], c[1])
+		}
+	}
+}
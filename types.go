@@ -1,8 +1,11 @@
 package main
 
 import (
+	"net/netip"
 	"sync"
 	"time"
+
+	"github.com/oschwald/maxminddb-golang/v2"
 )
 
 // Costanti HTTP
@@ -15,8 +18,23 @@ const (
 // Versione applicazione
 const AppVersion = "1.0.4"
 
-// Costante per posizione personalizzata
-const customLocationLabel = "Posizione personalizzata"
+// Ore mostrate nella timeline oraria
+const hourlyTimelineHours = 48
+
+// Sorgenti della posizione mostrata, in ordine di priorità
+const (
+	sourceURL     = "url"     // richiesta nell'URL (?lat=..&lon=.. o /place/{nome})
+	sourceBrowser = "browser" // visualizzazione temporanea dal browser
+	sourceSaved   = "saved"   // posizione salvata attiva
+	sourceIP      = "ip"      // geolocalizzazione dell'indirizzo IP
+	sourceDefault = "default" // posizione di default configurata
+)
+
+// Etichette delle posizioni senza nome
+const (
+	customLocationLabel  = "Posizione personalizzata"
+	browserLocationLabel = "📱 La mia posizione"
+)
 
 // Variabili globali - Config
 var (
@@ -26,32 +44,95 @@ var (
 	notificationInterval  time.Duration
 	notificationStartHour int
 	notificationEndHour   int
+	forecastDays          int
+	weeklyOutlook         bool
+	units                 Units
+	airQualityAlertAQI    float64
+	pollenAlertLevel      float64
+	settingsPath          string
+	defaultLocation       GeoLocation
+	publicURL             string // indirizzo pubblico della pagina, per i link nelle notifiche
+	nowcastAlerts         bool
+	nowcastLookahead      time.Duration
+	nowcastInterval       time.Duration // frequenza del controllo, indipendente dal riepilogo
+	nowcastWetMM          float64       // mm in 15 minuti oltre cui si considera pioggia
 
 	configMutex sync.RWMutex
 )
 
+// Variabili globali - Provider meteo
+var (
+	weatherProvider     WeatherProvider
+	openMeteoURL        string
+	metNoURL            string
+	metNoUserAgent      string
+	airQualityURL       string
+	archiveURL          string
+	geocoder            Geocoder
+	geocodingURL        string
+	nominatimURL        string
+	nominatimUserAgent  string
+	nominatimEmail      string
+	geoNamesFile        string
+	geoNamesCountryInfo string
+	geoNamesMaxKm       float64
+)
+
+// Variabili globali - Cache
+var (
+	forecastCache   *ttlCache[*Forecast]
+	geocodeCache    *ttlCache[GeoLocation]
+	ipCache         *ttlCache[GeoLocation]
+	searchCache     *ttlCache[[]GeoLocation]
+	airQualityCache *ttlCache[*AirQuality]
+	nowcastCache    *ttlCache[*Nowcast]
+
+	nominatimDiskCache *diskCache[GeoLocation]
+)
+
+// Variabili globali - Geolocalizzazione IP
+var (
+	trustedProxies []netip.Prefix
+	geoIPDB        *maxminddb.Reader
+	ipAPIURL       string
+)
+
 // Variabili globali - Stato notifiche
 var (
+	notifiers            []Notifier // canali configurati all'avvio
+	webhooksPath         string     // file con le definizioni dei webhook generici
 	notificationsEnabled = false
 	notificationsMutex   sync.RWMutex
 	ticker               *time.Ticker
 	stopChan             chan bool
 )
 
-// Variabili globali - Posizione personalizzata
+// Variabili globali - Posizioni salvate
+var (
+	savedLocations         []SavedLocation
+	nextLocationID         int
+	activeLocationID       string // posizione mostrata, "" = automatica
+	notificationLocationID string // posizione delle notifiche, "" = quella mostrata
+	locationMutex          sync.RWMutex
+)
+
+// Variabili globali - Regole di avviso
 var (
-	customLat     float64
-	customLon     float64
-	useCustom     bool
-	locationMutex sync.RWMutex
+	alertRules      []AlertRule
+	nextAlertRuleID int
+	alertMutex      sync.RWMutex
 )
 
 // GeoLocation rappresenta una posizione geografica
 type GeoLocation struct {
-	Lat     float64 `json:"lat"`
-	Lon     float64 `json:"lon"`
-	City    string  `json:"city"`
-	Country string  `json:"country"`
+	Lat      float64 `json:"lat"`
+	Lon      float64 `json:"lon"`
+	City     string  `json:"city"`
+	Admin    string  `json:"admin,omitempty"`
+	Country  string  `json:"country"`
+	Timezone string  `json:"timezone"`
+	Accuracy float64 `json:"accuracy,omitempty"` // raggio in metri, se noto
+	Source   string  `json:"source,omitempty"`   // sorgente della posizione
 }
 
 // WeatherData contiene i dati meteo per il template
@@ -60,19 +141,31 @@ type WeatherData struct {
 	Country              string
 	Lat                  float64
 	Lon                  float64
+	Accuracy             float64
+	LocationSource       string
 	Time                 string
+	Timezone             string
+	ObservedAt           time.Time
+	WeatherCode          int
 	CurrentCondition     string
 	CurrentTemp          float64
+	FeelsLike            float64
 	Humidity             float64
 	WindSpeed            float64
 	Visibility           float64
+	HasVisibility        bool
 	Precipitation        float64
-	TodayMax             float64
-	TodayMin             float64
-	TodayCondition       string
-	TomorrowMax          float64
-	TomorrowMin          float64
-	TomorrowCondition    string
+	UVIndex              float64
+	Sunrise              time.Time
+	Sunset               time.Time
+	Daylight             string
+	Days                 []DayForecast
+	Hours                []HourForecast
+	Units                Units
+	AirQuality           *AirQuality
+	Provider             string
+	Locations            LocationsResponse
+	AlertRules           []alertRulePayload
 	NotificationsEnabled bool
 	IntervalMinutes      int
 	StartHour            int
@@ -80,23 +173,129 @@ type WeatherData struct {
 	Version              string
 }
 
+// DayForecast contiene la previsione di una giornata
+type DayForecast struct {
+	Date                     time.Time `json:"date"`
+	Label                    string    `json:"label"`
+	Min                      float64   `json:"min"`
+	Max                      float64   `json:"max"`
+	WeatherCode              int       `json:"weather_code"`
+	Condition                string    `json:"condition"`
+	PrecipitationSum         float64   `json:"precipitation_sum"`
+	PrecipitationProbability float64   `json:"precipitation_probability"`
+	WindMax                  float64   `json:"wind_max"`
+	UVIndexMax               float64   `json:"uv_index_max"`
+	Sunrise                  time.Time `json:"sunrise"`
+	Sunset                   time.Time `json:"sunset"`
+	Daylight                 string    `json:"daylight"`
+}
+
+// HourForecast contiene la previsione di una singola ora
+type HourForecast struct {
+	Time                     time.Time `json:"time"`
+	Temperature              float64   `json:"temperature"`
+	ApparentTemperature      float64   `json:"apparent_temperature"`
+	WeatherCode              int       `json:"weather_code"`
+	Condition                string    `json:"condition"`
+	Precipitation            float64   `json:"precipitation"`
+	PrecipitationProbability float64   `json:"precipitation_probability"`
+	WindSpeed                float64   `json:"wind_speed"`
+	Humidity                 float64   `json:"humidity"`
+}
+
+// HourlyResponse rappresenta la risposta della timeline oraria
+type HourlyResponse struct {
+	City    string         `json:"city"`
+	Country string         `json:"country"`
+	Lat     float64        `json:"lat"`
+	Lon     float64        `json:"lon"`
+	Units   Units          `json:"units"`
+	Hours   []HourForecast `json:"hours"`
+}
+
+// AirQualityResponse rappresenta la risposta della qualità dell'aria
+type AirQualityResponse struct {
+	City       string      `json:"city"`
+	Country    string      `json:"country"`
+	Lat        float64     `json:"lat"`
+	Lon        float64     `json:"lon"`
+	AirQuality *AirQuality `json:"air_quality"`
+}
+
+// HistoryResponse rappresenta i dati storici di un periodo
+type HistoryResponse struct {
+	City      string         `json:"city"`
+	Country   string         `json:"country"`
+	Lat       float64        `json:"lat"`
+	Lon       float64        `json:"lon"`
+	Timezone  string         `json:"timezone"`
+	StartDate string         `json:"start_date"`
+	EndDate   string         `json:"end_date"`
+	Units     Units          `json:"units"`
+	Daily     []DayForecast  `json:"daily"`
+	Hourly    []HourForecast `json:"hourly"`
+}
+
+// SearchResponse rappresenta i candidati di una ricerca per nome
+type SearchResponse struct {
+	Query   string        `json:"query"`
+	Results []GeoLocation `json:"results"`
+}
+
 // UpdateConfigRequest rappresenta una richiesta di aggiornamento configurazione
 type UpdateConfigRequest struct {
-	IntervalMinutes int `json:"interval_minutes"`
-	StartHour       int `json:"start_hour"`
-	EndHour         int `json:"end_hour"`
+	IntervalMinutes int    `json:"interval_minutes"`
+	StartHour       int    `json:"start_hour"`
+	EndHour         int    `json:"end_hour"`
+	Units           *Units `json:"units,omitempty"`
 }
 
 // ConfigResponse rappresenta la risposta di configurazione
 type ConfigResponse struct {
-	IntervalMinutes int  `json:"interval_minutes"`
-	StartHour       int  `json:"start_hour"`
-	EndHour         int  `json:"end_hour"`
-	NotificationsOn bool `json:"notifications_on"`
+	IntervalMinutes int   `json:"interval_minutes"`
+	StartHour       int   `json:"start_hour"`
+	EndHour         int   `json:"end_hour"`
+	NotificationsOn bool  `json:"notifications_on"`
+	Units           Units `json:"units"`
 }
 
-// SetLocationRequest rappresenta una richiesta di impostazione posizione
+// SetLocationRequest rappresenta una richiesta di impostazione posizione;
+// con Name la posizione viene aggiunta a quelle salvate
 type SetLocationRequest struct {
-	Lat float64 `json:"lat"`
-	Lon float64 `json:"lon"`
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lon"`
+	Name string  `json:"name,omitempty"`
+}
+
+// BrowserLocationRequest rappresenta una posizione rilevata dal browser;
+// con Save diventa la posizione delle notifiche, altrimenti è solo visualizzata
+type BrowserLocationRequest struct {
+	Lat      float64 `json:"lat"`
+	Lon      float64 `json:"lon"`
+	Accuracy float64 `json:"accuracy"`
+	Save     bool    `json:"save"`
+}
+
+// SelectLocationRequest seleziona una posizione salvata ("" = automatica)
+type SelectLocationRequest struct {
+	ID string `json:"id"`
+}
+
+// LocationsResponse elenca le posizioni salvate e quelle selezionate
+type LocationsResponse struct {
+	Locations      []SavedLocation `json:"locations"`
+	ActiveID       string          `json:"active_id"`
+	NotificationID string          `json:"notification_id"`
+}
+
+// CacheStats rappresenta le statistiche di una cache
+type CacheStats struct {
+	Entries    int    `json:"entries"`
+	Hits       uint64 `json:"hits"`
+	StaleHits  uint64 `json:"stale_hits"`
+	Misses     uint64 `json:"misses"`
+	Errors     uint64 `json:"errors"`
+	Evictions  uint64 `json:"evictions"`
+	MaxEntries int    `json:"max_entries"`
+	TTLSeconds int    `json:"ttl_seconds"`
 }
@@ -87,7 +87,7 @@ h1{
     display:block;
     margin-bottom:6px;
 }
-.config-panel input{
+.config-panel input,.config-panel select{
     width:80px;
     padding:4px 6px;
     margin-left:4px;
@@ -111,8 +111,10 @@ h1{
     border-radius:15px;
     margin-bottom:20px;
 }
-.current h2{font-size:1.3em;margin-bottom:20px;}
-.temp-big{font-size:4em;font-weight:bold;margin:20px 0;}
+.current h2{font-size:1.3em;margin-bottom:5px;}
+.observed{font-size:.85em;opacity:.8;margin-bottom:15px;}
+.temp-big{font-size:4em;font-weight:bold;margin:20px 0 5px;}
+.feels-like{opacity:.85;margin-bottom:20px;}
 .details{
     display:grid;
     grid-template-columns:1fr 1fr;
@@ -126,19 +128,52 @@ h1{
 }
 .detail-label{font-size:.9em;opacity:.9;}
 .detail-value{font-size:1.3em;font-weight:bold;margin-top:5px;}
+.air-quality{background:#f8f9fa;border-radius:15px;padding:20px;margin-bottom:20px;border:2px solid #e9ecef;}
+.air-quality h3{color:#667eea;margin-bottom:10px;}
+.aqi{font-size:1.2em;font-weight:600;margin-bottom:10px;}
+.aq-grid{display:grid;grid-template-columns:repeat(4,1fr);gap:10px;font-size:.85em;color:#666;}
+.aq-grid span{display:block;}
+.aq-grid b{font-size:1.3em;color:#333;}
+.pollen{margin-top:10px;display:flex;flex-wrap:wrap;gap:8px;font-size:.85em;}
+.pollen-item{background:#fff3cd;border-radius:12px;padding:4px 10px;}
+.timeline{margin-bottom:20px;}
+.timeline h3{color:#667eea;margin-bottom:10px;}
+.timeline-scroll{overflow-x:auto;background:#f8f9fa;border-radius:15px;padding:10px;}
+.timeline-legend{display:flex;gap:20px;justify-content:center;font-size:.85em;margin-top:8px;color:#666;}
+.legend-temp{color:#764ba2;}
+.legend-rain{color:#007bff;}
 .forecast{
-    display:grid;
-    grid-template-columns:1fr 1fr;
-    gap:20px;
+    display:flex;
+    gap:15px;
+    overflow-x:auto;
+    padding-bottom:10px;
+    scroll-snap-type:x mandatory;
 }
 .forecast-card{
     background:#f8f9fa;
     padding:20px;
     border-radius:15px;
     border:2px solid #e9ecef;
+    flex:0 0 150px;
+    scroll-snap-align:start;
 }
-.forecast-card h3{color:#667eea;margin-bottom:10px;}
-.forecast-temp{font-size:1.4em;color:#333;margin:10px 0;}
+.forecast-card h3{color:#667eea;margin-bottom:10px;font-size:1em;}
+.forecast-temp{font-size:1.3em;color:#333;margin:10px 0;}
+.forecast-extra{font-size:.85em;color:#666;}
+.location-switcher{display:flex;gap:6px;justify-content:center;align-items:center;margin-top:10px;}
+.location-switcher select{padding:6px 10px;border-radius:20px;border:1px solid #ccc;}
+.icon-btn{background:none;border:none;cursor:pointer;font-size:1.1em;}
+.config-panel select.wide{width:auto;}
+.search-box{display:flex;gap:8px;margin-top:10px;}
+.search-box input{flex:1;padding:8px 12px;border-radius:20px;border:1px solid #ccc;}
+.search-results{list-style:none;margin-top:6px;max-height:150px;overflow-y:auto;}
+.search-results li{padding:6px 10px;border-radius:8px;cursor:pointer;}
+.search-results li:hover{background:#f0f2ff;}
+#locationNameInput{width:100%;padding:8px 12px;border-radius:20px;border:1px solid #ccc;}
+.location-source{color:#999;}
+.browser-view{background:#fff3cd;border-radius:12px;padding:8px 12px;margin-top:8px;font-size:.9em;}
+.browser-choice{display:none;background:#f8f9fa;border-radius:12px;padding:10px 12px;margin-bottom:10px;}
+.browser-choice .map-buttons{margin-top:8px;}
 .location-btn{background:#667eea;color:white;padding:8px 16px;border:none;border-radius:20px;cursor:pointer;font-size:.9em;margin-top:10px;}
 .location-btn:hover{background:#5568d3;}
 .modal{display:none;position:fixed;z-index:1000;left:0;top:0;width:100%;height:100%;background:rgba(0,0,0,0.5);}
@@ -165,6 +200,21 @@ h1{
 @keyframes slideIn{from{transform:translateX(400px);opacity:0;}to{transform:translateX(0);opacity:1;}}
 @keyframes slideOut{from{transform:translateX(0);opacity:1;}to{transform:translateX(400px);opacity:0;}}
 .toast.hiding{animation:slideOut 0.3s ease;}
+.history-panel{margin-top:20px;padding:15px;border-radius:12px;background:#f8f9fa;font-size:.9em;}
+.history-panel h3{margin-bottom:8px;color:#444;}
+.history-form{display:flex;flex-wrap:wrap;gap:10px;align-items:center;}
+.history-form input{padding:4px 6px;}
+.history-form .config-save{margin-top:0;}
+.history-links{display:flex;gap:15px;margin-top:8px;}
+.history-links a{color:#667eea;}
+.history-table{width:100%;border-collapse:collapse;margin-top:10px;}
+.history-table th,.history-table td{padding:4px 6px;border-bottom:1px solid #e9ecef;text-align:left;}
+.alert-hint{color:#888;}
+.alert-list{list-style:none;margin:10px 0;}
+.alert-list li{display:flex;flex-wrap:wrap;align-items:center;gap:8px;padding:4px 0;border-bottom:1px solid #e9ecef;}
+.alert-list li small{color:#888;flex:1;}
+.alert-form select,.alert-form input{padding:4px 6px;}
+.alert-form input[type=number]{width:70px;}
 .footer{text-align:center;margin-top:30px;padding-top:20px;border-top:1px solid #e9ecef;color:#999;font-size:0.85em;}
 </style>
 </head>
@@ -195,20 +245,70 @@ h1{
             Alle (ora):
             <input id="endHourInput" type="number" min="0" max="23" value="{{.EndHour}}">
         </label>
+        <label>
+            Unità:
+            <select id="unitSystemInput">
+                <option value="metric" {{if eq .Units.System "metric"}}selected{{end}}>Metriche (°C, mm)</option>
+                <option value="imperial" {{if eq .Units.System "imperial"}}selected{{end}}>Imperiali (°F, in)</option>
+            </select>
+        </label>
+        <label>
+            Vento:
+            <select id="windUnitInput">
+                <option value="kmh" {{if eq .Units.Wind "kmh"}}selected{{end}}>km/h</option>
+                <option value="ms" {{if eq .Units.Wind "ms"}}selected{{end}}>m/s</option>
+                <option value="kn" {{if eq .Units.Wind "kn"}}selected{{end}}>nodi</option>
+                <option value="mph" {{if eq .Units.Wind "mph"}}selected{{end}}>mph</option>
+                <option value="beaufort" {{if eq .Units.Wind "beaufort"}}selected{{end}}>Beaufort</option>
+            </select>
+        </label>
+        <label>
+            Posizione notifiche:
+            <select id="notificationLocationInput" class="wide">
+                <option value="">Come la pagina</option>
+                {{range .Locations.Locations}}<option value="{{.ID}}" {{if eq .ID $.Locations.NotificationID}}selected{{end}}>{{.Name}}</option>{{end}}
+            </select>
+        </label>
         <button id="saveConfigBtn" class="config-save">💾 Salva configurazione</button>
     </div>
 
     <div class="location">
         📍 {{.City}}, {{.Country}}<br>
-        <small>{{printf "%.4f" .Lat}}, {{printf "%.4f" .Lon}}</small><br>
+        <small>{{printf "%.4f" .Lat}}, {{printf "%.4f" .Lon}}{{if .Accuracy}} (±{{printf "%.0f" .Accuracy}} m){{end}}</small><br>
+        <small class="location-source">{{.LocationSourceLabel}}</small>
+        <button class="icon-btn" id="copyLinkBtn" title="Copia il link a questa posizione">🔗</button><br>
+        {{if eq .LocationSource "url"}}
+        <div class="browser-view">
+            🔗 Posizione aperta da un link, le posizioni salvate non cambiano
+            <a class="icon-btn" href="/meteo/" title="Torna alla posizione abituale">↩️</a>
+        </div>
+        {{end}}
+        {{if eq .LocationSource "browser"}}
+        <div class="browser-view">
+            📱 Visualizzazione temporanea della posizione del browser
+            <button class="icon-btn" id="endBrowserViewBtn" title="Torna alla posizione abituale">↩️</button>
+        </div>
+        {{end}}
+        <div class="location-switcher">
+            <select id="locationSelect">
+                <option value="">📡 Posizione automatica</option>
+                {{range .Locations.Locations}}<option value="{{.ID}}" {{if eq .ID $.Locations.ActiveID}}selected{{end}}>{{.Name}}</option>{{end}}
+            </select>
+            {{if .Locations.ActiveID}}
+            <button class="icon-btn" id="renameLocationBtn" title="Rinomina">✏️</button>
+            <button class="icon-btn" id="deleteLocationBtn" title="Elimina">🗑️</button>
+            {{end}}
+        </div>
         <button class="location-btn" id="openMapBtn">🗺️ Scegli posizione sulla mappa</button>
     </div>
-    <div class="time">🕐 {{.Time}}</div>
+    <div class="time">🕐 {{.Time}} ({{.Timezone}})</div>
 
     <div class="current">
         <h2>Condizioni Attuali</h2>
+        <div class="observed">Rilevate alle {{.ObservedAt.Format "15:04"}}</div>
         <div>{{.CurrentCondition}}</div>
-        <div class="temp-big">{{printf "%.1f" .CurrentTemp}}°C</div>
+        <div class="temp-big">{{printf "%.1f" .CurrentTemp}}{{.Units.TemperatureLabel}}</div>
+        <div class="feels-like">Percepita {{printf "%.1f" .FeelsLike}}{{.Units.TemperatureLabel}}</div>
 
         <div class="details">
             <div class="detail-item">
@@ -217,40 +317,142 @@ h1{
             </div>
             <div class="detail-item">
                 <div class="detail-label">💨 Vento</div>
-                <div class="detail-value">{{printf "%.1f" .WindSpeed}} km/h</div>
+                <div class="detail-value">{{printf "%.1f" .WindSpeed}} {{.Units.WindLabel}}</div>
             </div>
             <div class="detail-item">
                 <div class="detail-label">👁️ Visibilità</div>
-                <div class="detail-value">{{printf "%.1f" .Visibility}} km</div>
+                <div class="detail-value">{{if .HasVisibility}}{{printf "%.1f" .Visibility}} {{.Units.VisibilityLabel}}{{else}}—{{end}}</div>
             </div>
             <div class="detail-item">
                 <div class="detail-label">🌧️ Precipitazioni</div>
-                <div class="detail-value">{{printf "%.1f" .Precipitation}} mm</div>
+                <div class="detail-value">{{printf "%.1f" .Precipitation}} {{.Units.PrecipitationLabel}}</div>
+            </div>
+            <div class="detail-item">
+                <div class="detail-label">🔆 Indice UV max</div>
+                <div class="detail-value">{{printf "%.1f" .UVIndex}}</div>
+            </div>
+            <div class="detail-item">
+                <div class="detail-label">🌅 Alba / 🌇 Tramonto</div>
+                <div class="detail-value">{{if .Sunrise.IsZero}}—{{else}}{{.Sunrise.Format "15:04"}} / {{.Sunset.Format "15:04"}}{{end}}</div>
+                {{if .Daylight}}<div class="detail-label">☀️ {{.Daylight}} di luce</div>{{end}}
             </div>
         </div>
     </div>
 
+    {{with .AirQuality}}
+    <div class="air-quality">
+        <h3>🌬️ Qualità dell'aria</h3>
+        <div class="aqi">{{.Level}} · AQI europeo {{printf "%.0f" .EuropeanAQI}}</div>
+        <div class="aq-grid">
+            <div><span>PM2.5</span><b>{{printf "%.0f" .PM25}}</b> µg/m³</div>
+            <div><span>PM10</span><b>{{printf "%.0f" .PM10}}</b> µg/m³</div>
+            <div><span>O₃</span><b>{{printf "%.0f" .Ozone}}</b> µg/m³</div>
+            <div><span>NO₂</span><b>{{printf "%.0f" .NO2}}</b> µg/m³</div>
+        </div>
+        <div class="pollen">
+            {{range .Pollen}}{{if gt .Value 0.0}}<span class="pollen-item">🌾 {{.Name}} {{printf "%.0f" .Value}}</span>{{end}}{{end}}
+        </div>
+    </div>
+    {{end}}
+
+    <div class="timeline">
+        <h3>🕒 Prossime 48 ore</h3>
+        <div class="timeline-scroll">
+            <canvas id="hourlyChart" height="200"></canvas>
+        </div>
+        <div class="timeline-legend">
+            <span class="legend-temp">━ Temperatura ({{.Units.TemperatureLabel}})</span>
+            <span class="legend-rain">▮ Precipitazioni ({{.Units.PrecipitationLabel}})</span>
+        </div>
+    </div>
+
     <div class="forecast">
+        {{range .Days}}
         <div class="forecast-card">
-            <h3>📅 Oggi</h3>
-            <div>{{.TodayCondition}}</div>
+            <h3>📅 {{.Label}}</h3>
+            <div>{{.Condition}}</div>
             <div class="forecast-temp">
-                Max: {{printf "%.1f" .TodayMax}}°C<br>
-                Min: {{printf "%.1f" .TodayMin}}°C
+                {{printf "%.0f" .Max}}° / {{printf "%.0f" .Min}}{{$.Units.TemperatureLabel}}
             </div>
-        </div>
-        <div class="forecast-card">
-            <h3>📅 Domani</h3>
-            <div>{{.TomorrowCondition}}</div>
-            <div class="forecast-temp">
-                Max: {{printf "%.1f" .TomorrowMax}}°C<br>
-                Min: {{printf "%.1f" .TomorrowMin}}°C
+            <div class="forecast-extra">
+                🌧️ {{printf "%.1f" .PrecipitationSum}} {{$.Units.PrecipitationLabel}} ({{printf "%.0f" .PrecipitationProbability}}%)<br>
+                💨 max {{printf "%.0f" .WindMax}} {{$.Units.WindLabel}}<br>
+                🔆 UV {{printf "%.0f" .UVIndexMax}}<br>
+                {{if not .Sunrise.IsZero}}🌅 {{.Sunrise.Format "15:04"}} 🌇 {{.Sunset.Format "15:04"}}{{end}}
             </div>
         </div>
+        {{end}}
     </div>
     
+    <div class="history-panel">
+        <h3>📜 Meteo storico</h3>
+        <div class="history-form">
+            <label>Dal <input id="historyStart" type="date"></label>
+            <label>Al <input id="historyEnd" type="date"></label>
+            <button id="historyBtn" class="config-save">🔍 Cerca</button>
+        </div>
+        <div class="history-links">
+            <a id="historyCsvDaily" href="#">⬇️ CSV giornaliero</a>
+            <a id="historyCsvHourly" href="#">⬇️ CSV orario</a>
+        </div>
+        <div id="historyResult"></div>
+    </div>
+
+    <div class="history-panel">
+        <h3>⚠️ Regole di avviso</h3>
+        <small class="alert-hint">Con almeno una regola attiva le notifiche periodiche inviano solo gli avvisi scattati, al posto del riepilogo.</small>
+        <ul class="alert-list">
+            {{range .AlertRules}}
+            <li data-id="{{.ID}}">
+                <label><input type="checkbox" class="alert-enabled" {{if .AlertRule.Enabled}}checked{{end}}> {{.Name}}</label>
+                <small>{{.Description $.Units}} · pausa {{.CooldownMinutes}} min{{if not .LastFired.IsZero}} · ultimo avviso {{.LastFired.Format "02/01 15:04"}}{{end}}</small>
+                <button class="icon-btn alert-delete" title="Elimina">🗑️</button>
+            </li>
+            {{else}}
+            <li><small>Nessuna regola: le notifiche inviano il riepilogo periodico.</small></li>
+            {{end}}
+        </ul>
+        <div class="history-form alert-form">
+            <input id="alertName" type="text" placeholder="Nome (opzionale)">
+            <select id="alertMetric">
+                <option value="temperature">🌡️ Temperatura ({{.Units.TemperatureLabel}})</option>
+                <option value="feels_like">🌡️ Percepita ({{.Units.TemperatureLabel}})</option>
+                <option value="wind_speed">💨 Vento ({{.Units.WindLabel}})</option>
+                <option value="precipitation">🌧️ Precipitazioni ({{.Units.PrecipitationLabel}})</option>
+                <option value="precipitation_probability">☔ Probabilità pioggia (%)</option>
+                <option value="humidity">💧 Umidità (%)</option>
+                <option value="condition">🌦️ Condizione</option>
+                <option value="uv_index" data-current-only>🔆 UV max di oggi</option>
+                <option value="aqi" data-current-only>🌫️ AQI europeo</option>
+            </select>
+            <select id="alertOperator">
+                <option value="<">&lt;</option>
+                <option value="<=">&le;</option>
+                <option value=">">&gt;</option>
+                <option value=">=">&ge;</option>
+                <option value="=">=</option>
+                <option value="!=">&ne;</option>
+            </select>
+            <input id="alertValue" type="number" step="any" value="0">
+            <select id="alertCondition" style="display:none;">
+                <option value="thunderstorm">⛈️ Temporale</option>
+                <option value="rain">🌧️ Pioggia</option>
+                <option value="snow">❄️ Neve</option>
+                <option value="drizzle">🌦️ Pioviggine</option>
+                <option value="fog">🌫️ Nebbia</option>
+                <option value="cloudy">☁️ Nuvoloso</option>
+                <option value="clear">☀️ Sereno</option>
+            </select>
+            <label>nelle prossime <input id="alertHours" type="number" min="0" max="48" value="12"> ore</label>
+            <label>pausa <input id="alertCooldown" type="number" min="1" value="180"> min</label>
+            <button id="addAlertBtn" class="config-save">➕ Aggiungi</button>
+            <button id="checkAlertsBtn" class="config-save">🔍 Verifica ora</button>
+        </div>
+        <div id="alertCheckResult"></div>
+    </div>
+
     <div class="footer">
-        ⚙️ Meteo App v{{.Version}}
+        ⚙️ Meteo App v{{.Version}} · dati {{.Provider}}
     </div>
 </div>
 
@@ -260,9 +462,23 @@ h1{
             <h2>🗺️ Scegli posizione meteo</h2>
             <span class="close" id="closeModal">&times;</span>
         </div>
-        <p>Clicca sulla mappa per selezionare la posizione desiderata</p>
+        <p>Cerca una località o clicca sulla mappa per selezionare la posizione desiderata</p>
+        <div class="search-box">
+            <input id="searchInput" type="search" placeholder="Es. Bologna">
+            <button class="btn btn-primary" id="searchBtn">🔍 Cerca</button>
+        </div>
+        <ul class="search-results" id="searchResults"></ul>
         <div id="map"></div>
+        <input id="locationNameInput" type="text" placeholder="Nome (facoltativo, es. Casa) per aggiungerla alle posizioni salvate">
+        <div class="browser-choice" id="browserChoice">
+            <div id="browserChoiceText"></div>
+            <div class="map-buttons">
+                <button class="btn btn-secondary" id="browserViewBtn">👁️ Mostra solo ora</button>
+                <button class="btn btn-primary" id="browserSaveBtn">🔔 Usa per le notifiche</button>
+            </div>
+        </div>
         <div class="map-buttons">
+            <button class="btn btn-secondary" id="browserLocationBtn">📱 Usa la mia posizione</button>
             <button class="btn btn-secondary" id="resetLocationBtn">🔄 Usa posizione automatica</button>
             <button class="btn btn-primary" id="saveLocationBtn">💾 Salva posizione</button>
         </div>
@@ -274,12 +490,46 @@ const toggleBtn = document.getElementById("notificationToggle");
 const intervalInput = document.getElementById("intervalInput");
 const startHourInput = document.getElementById("startHourInput");
 const endHourInput = document.getElementById("endHourInput");
+const unitSystemInput = document.getElementById("unitSystemInput");
+const windUnitInput = document.getElementById("windUnitInput");
 const saveConfigBtn = document.getElementById("saveConfigBtn");
 const openMapBtn = document.getElementById("openMapBtn");
 const mapModal = document.getElementById("mapModal");
 const closeModal = document.getElementById("closeModal");
 const saveLocationBtn = document.getElementById("saveLocationBtn");
 const resetLocationBtn = document.getElementById("resetLocationBtn");
+const locationSelect = document.getElementById("locationSelect");
+const renameLocationBtn = document.getElementById("renameLocationBtn");
+const deleteLocationBtn = document.getElementById("deleteLocationBtn");
+const notificationLocationInput = document.getElementById("notificationLocationInput");
+const locationNameInput = document.getElementById("locationNameInput");
+const browserLocationBtn = document.getElementById("browserLocationBtn");
+const browserChoice = document.getElementById("browserChoice");
+const browserChoiceText = document.getElementById("browserChoiceText");
+const browserViewBtn = document.getElementById("browserViewBtn");
+const browserSaveBtn = document.getElementById("browserSaveBtn");
+const endBrowserViewBtn = document.getElementById("endBrowserViewBtn");
+const copyLinkBtn = document.getElementById("copyLinkBtn");
+const searchInput = document.getElementById("searchInput");
+const searchBtn = document.getElementById("searchBtn");
+const searchResults = document.getElementById("searchResults");
+const historyStart = document.getElementById("historyStart");
+const historyEnd = document.getElementById("historyEnd");
+const historyBtn = document.getElementById("historyBtn");
+const historyCsvDaily = document.getElementById("historyCsvDaily");
+const historyCsvHourly = document.getElementById("historyCsvHourly");
+const historyResult = document.getElementById("historyResult");
+const alertMetric = document.getElementById("alertMetric");
+const alertOperator = document.getElementById("alertOperator");
+const alertValue = document.getElementById("alertValue");
+const alertCondition = document.getElementById("alertCondition");
+const alertHours = document.getElementById("alertHours");
+const alertCheckResult = document.getElementById("alertCheckResult");
+
+const hours = {{.Hours}};
+const savedLocations = {{.Locations.Locations}};
+const activeLocationID = {{.Locations.ActiveID}};
+const alertRules = {{.AlertRules}} || [];
 
 let map;
 let marker;
@@ -311,6 +561,63 @@ function showToast(message, type = "info") {
     }, 4000);
 }
 
+// Disegna la timeline oraria di temperatura e precipitazioni
+function drawHourlyChart() {
+    const canvas = document.getElementById("hourlyChart");
+    if (!hours || hours.length === 0) {
+        canvas.parentElement.textContent = "Nessun dato orario disponibile";
+        return;
+    }
+    const step = 28;
+    const top = 20;
+    const bottom = 30;
+    canvas.width = hours.length * step + 20;
+    const height = canvas.height - top - bottom;
+    const ctx = canvas.getContext("2d");
+
+    const temps = hours.map(h => h.temperature);
+    const tMin = Math.min(...temps) - 1;
+    const tMax = Math.max(...temps) + 1;
+    const pMax = Math.max(2, ...hours.map(h => h.precipitation));
+    const x = i => 10 + i * step + step / 2;
+    const yTemp = t => top + height - (t - tMin) / (tMax - tMin) * height;
+
+    ctx.font = "11px Segoe UI, sans-serif";
+    ctx.textAlign = "center";
+
+    // Barre precipitazioni
+    ctx.fillStyle = "rgba(0,123,255,0.5)";
+    hours.forEach((h, i) => {
+        const barHeight = h.precipitation / pMax * height;
+        ctx.fillRect(x(i) - step / 3, top + height - barHeight, step * 2 / 3, barHeight);
+    });
+
+    // Linea temperatura
+    ctx.strokeStyle = "#764ba2";
+    ctx.lineWidth = 2;
+    ctx.beginPath();
+    hours.forEach((h, i) => {
+        if (i === 0) ctx.moveTo(x(i), yTemp(h.temperature));
+        else ctx.lineTo(x(i), yTemp(h.temperature));
+    });
+    ctx.stroke();
+
+    // Etichette ogni 3 ore (ora locale della posizione)
+    hours.forEach((h, i) => {
+        if (i % 3 !== 0) return;
+        ctx.fillStyle = "#764ba2";
+        ctx.fillText(h.temperature.toFixed(0) + "°", x(i), yTemp(h.temperature) - 6);
+        ctx.fillStyle = "#666";
+        ctx.fillText(h.time.substring(11, 13), x(i), canvas.height - 10);
+        if (h.precipitation > 0) {
+            ctx.fillStyle = "#007bff";
+            ctx.fillText(h.precipitation.toFixed(1), x(i), canvas.height - 22);
+        }
+    });
+}
+
+drawHourlyChart();
+
 // Inizializza mappa
 function initMap() {
     if (!map) {
@@ -335,6 +642,117 @@ function initMap() {
     }
 }
 
+// Ricerca località per nome
+async function searchPlaces() {
+    const query = searchInput.value.trim();
+    if (!query) return;
+    try {
+        const res = await fetch("/meteo/geocode/search?q=" + encodeURIComponent(query));
+        if (!res.ok) throw new Error(await res.text());
+        const data = await res.json();
+        searchResults.innerHTML = "";
+        if (data.results.length === 0) {
+            showToast("Nessuna località trovata per " + query, "info");
+            return;
+        }
+        data.results.forEach(place => {
+            const item = document.createElement("li");
+            item.textContent = "📍 " + [place.city, place.admin, place.country].filter(Boolean).join(", ");
+            item.addEventListener("click", () => {
+                selectedLat = place.lat;
+                selectedLon = place.lon;
+                marker.setLatLng([place.lat, place.lon]);
+                map.setView([place.lat, place.lon], 11);
+                locationNameInput.value = place.city;
+                searchResults.innerHTML = "";
+            });
+            searchResults.appendChild(item);
+        });
+    } catch (e) {
+        console.error(e);
+        showToast("Errore ricerca: " + e.message, "error");
+    }
+}
+
+searchBtn.addEventListener("click", searchPlaces);
+searchInput.addEventListener("keydown", (e) => {
+    if (e.key === "Enter") searchPlaces();
+});
+
+// Posizione rilevata dal browser
+let browserCoords;
+
+browserLocationBtn.addEventListener("click", () => {
+    if (!navigator.geolocation) {
+        showToast("Geolocalizzazione non supportata dal browser", "error");
+        return;
+    }
+    navigator.geolocation.getCurrentPosition((pos) => {
+        browserCoords = pos.coords;
+        selectedLat = pos.coords.latitude;
+        selectedLon = pos.coords.longitude;
+        marker.setLatLng([selectedLat, selectedLon]);
+        map.setView([selectedLat, selectedLon], 13);
+        browserChoiceText.textContent = "📱 Posizione rilevata: " + selectedLat.toFixed(4) + ", " +
+            selectedLon.toFixed(4) + " (±" + Math.round(pos.coords.accuracy) + " m)";
+        browserChoice.style.display = "block";
+    }, (err) => {
+        showToast("Posizione non disponibile: " + err.message, "error");
+    }, {enableHighAccuracy: true, timeout: 15000});
+});
+
+async function sendBrowserLocation(save) {
+    try {
+        await postLocation("/meteo/location/browser", "POST", {
+            lat: browserCoords.latitude,
+            lon: browserCoords.longitude,
+            accuracy: browserCoords.accuracy,
+            save: save
+        });
+        mapModal.style.display = "none";
+        if (save) {
+            showToast("Posizione salvata per le notifiche! Ricaricamento...", "success");
+        } else {
+            showToast("Visualizzazione della tua posizione! Ricaricamento...", "success");
+        }
+        setTimeout(() => location.reload(), 1000);
+    } catch (e) {
+        console.error(e);
+        showToast("Errore posizione: " + e.message, "error");
+    }
+}
+
+browserViewBtn.addEventListener("click", () => sendBrowserLocation(false));
+browserSaveBtn.addEventListener("click", () => sendBrowserLocation(true));
+
+// Link condivisibile alla posizione mostrata
+const pageLat = {{.Lat}};
+const pageLon = {{.Lon}};
+const fromLink = {{eq .LocationSource "url"}};
+const locationQuery = "lat=" + pageLat.toFixed(4) + "&lon=" + pageLon.toFixed(4);
+
+copyLinkBtn.addEventListener("click", async () => {
+    const link = location.origin + "/meteo/?" + locationQuery;
+    try {
+        await navigator.clipboard.writeText(link);
+        showToast("🔗 Link copiato", "success");
+    } catch (e) {
+        prompt("Copia il link:", link);
+    }
+});
+
+if (endBrowserViewBtn) {
+    endBrowserViewBtn.addEventListener("click", async () => {
+        try {
+            await postLocation("/meteo/location/browser", "DELETE");
+            location.reload();
+        } catch (e) {
+            console.error(e);
+            showToast("Errore posizione: " + e.message, "error");
+        }
+    });
+}
+
 // Apri modale mappa
 openMapBtn.addEventListener("click", () => {
     mapModal.style.display = "block";
@@ -361,7 +779,7 @@ saveLocationBtn.addEventListener("click", async () => {
         const res = await fetch("/meteo/location/set", {
             method: "POST",
             headers: {"Content-Type": "application/json"},
-            body: JSON.stringify({lat: selectedLat, lon: selectedLon})
+            body: JSON.stringify({lat: selectedLat, lon: selectedLon, name: locationNameInput.value.trim()})
         });
         if (!res.ok) throw new Error("Errore salvataggio posizione");
         showToast("Posizione salvata! Ricaricamento...", "success");
@@ -387,6 +805,189 @@ resetLocationBtn.addEventListener("click", async () => {
     }
 });
 
+// Posizioni salvate
+async function postLocation(url, method, payload) {
+    const res = await fetch(url, {
+        method: method,
+        headers: {"Content-Type": "application/json"},
+        body: payload === undefined ? undefined : JSON.stringify(payload)
+    });
+    if (!res.ok) throw new Error(await res.text());
+    return res.json();
+}
+
+locationSelect.addEventListener("change", async () => {
+    try {
+        await postLocation("/meteo/locations/activate", "POST", {id: locationSelect.value});
+        showToast("Posizione cambiata! Ricaricamento...", "success");
+        setTimeout(() => location.reload(), 1000);
+    } catch (e) {
+        console.error(e);
+        showToast("Errore posizione: " + e.message, "error");
+    }
+});
+
+if (renameLocationBtn) {
+    renameLocationBtn.addEventListener("click", async () => {
+        const current = savedLocations.find(l => l.id === activeLocationID);
+        const name = prompt("Nuovo nome della posizione", current.name);
+        if (!name) return;
+        try {
+            await postLocation("/meteo/locations/" + current.id, "PUT", {...current, name: name});
+            showToast("Posizione rinominata! Ricaricamento...", "success");
+            setTimeout(() => location.reload(), 1000);
+        } catch (e) {
+            console.error(e);
+            showToast("Errore posizione: " + e.message, "error");
+        }
+    });
+}
+
+if (deleteLocationBtn) {
+    deleteLocationBtn.addEventListener("click", async () => {
+        const current = savedLocations.find(l => l.id === activeLocationID);
+        if (!confirm("Eliminare " + current.name + "?")) return;
+        try {
+            await postLocation("/meteo/locations/" + current.id, "DELETE");
+            showToast("Posizione eliminata! Ricaricamento...", "success");
+            setTimeout(() => location.reload(), 1000);
+        } catch (e) {
+            console.error(e);
+            showToast("Errore posizione: " + e.message, "error");
+        }
+    });
+}
+
+// Regole di avviso
+function updateAlertForm() {
+    const option = alertMetric.selectedOptions[0];
+    const isCondition = alertMetric.value === "condition";
+    alertValue.style.display = isCondition ? "none" : "";
+    alertCondition.style.display = isCondition ? "" : "none";
+    if (isCondition && alertOperator.value !== "=" && alertOperator.value !== "!=") {
+        alertOperator.value = "=";
+    }
+    if (option.hasAttribute("data-current-only")) {
+        alertHours.value = 0;
+    }
+    alertHours.disabled = option.hasAttribute("data-current-only");
+}
+alertMetric.addEventListener("change", updateAlertForm);
+updateAlertForm();
+
+async function changeAlerts(url, method, payload, message) {
+    try {
+        await postLocation(url, method, payload);
+        showToast(message + " Ricaricamento...", "success");
+        setTimeout(() => location.reload(), 1000);
+    } catch (e) {
+        console.error(e);
+        showToast("Errore regola: " + e.message, "error");
+    }
+}
+
+document.getElementById("addAlertBtn").addEventListener("click", () => {
+    changeAlerts("/meteo/alerts", "POST", {
+        name: document.getElementById("alertName").value,
+        metric: alertMetric.value,
+        operator: alertOperator.value,
+        value: parseFloat(alertValue.value) || 0,
+        condition: alertCondition.value,
+        within_hours: parseInt(alertHours.value) || 0,
+        cooldown_minutes: parseInt(document.getElementById("alertCooldown").value) || 0,
+        enabled: true
+    }, "Regola aggiunta!");
+});
+
+document.querySelectorAll(".alert-list li[data-id]").forEach(item => {
+    const rule = alertRules.find(r => r.id === item.dataset.id);
+    item.querySelector(".alert-enabled").addEventListener("change", e => {
+        changeAlerts("/meteo/alerts/" + rule.id, "PUT", {...rule, enabled: e.target.checked},
+            e.target.checked ? "Regola attivata!" : "Regola disattivata!");
+    });
+    item.querySelector(".alert-delete").addEventListener("click", () => {
+        if (!confirm("Eliminare la regola " + rule.name + "?")) return;
+        changeAlerts("/meteo/alerts/" + rule.id, "DELETE", undefined, "Regola eliminata!");
+    });
+});
+
+document.getElementById("checkAlertsBtn").addEventListener("click", async () => {
+    try {
+        const res = await fetch("/meteo/alerts/check");
+        if (!res.ok) throw new Error(await res.text());
+        const events = await res.json();
+        alertCheckResult.textContent = "";
+        if (events.length === 0) {
+            alertCheckResult.textContent = "✅ Nessuna regola verificata in questo momento";
+            return;
+        }
+        const list = document.createElement("ul");
+        list.className = "alert-list";
+        events.forEach(e => {
+            const li = document.createElement("li");
+            li.textContent = "⚠️ " + e.name + ": " + e.message;
+            list.appendChild(li);
+        });
+        alertCheckResult.appendChild(list);
+    } catch (e) {
+        console.error(e);
+        showToast("Errore verifica: " + e.message, "error");
+    }
+});
+
+notificationLocationInput.addEventListener("change", async () => {
+    try {
+        await postLocation("/meteo/locations/notify", "POST", {id: notificationLocationInput.value});
+        showToast("Posizione delle notifiche aggiornata", "success");
+    } catch (e) {
+        console.error(e);
+        showToast("Errore posizione: " + e.message, "error");
+    }
+});
+
+// Storico: di default l'ultima settimana conclusa
+function isoDate(d) {
+    return d.toISOString().substring(0, 10);
+}
+
+function historyURL(extra) {
+    const place = fromLink ? "&" + locationQuery : "";
+    return "/meteo/history?start=" + historyStart.value + "&end=" + historyEnd.value + place + extra;
+}
+
+function updateHistoryLinks() {
+    historyCsvDaily.href = historyURL("&format=csv&series=daily");
+    historyCsvHourly.href = historyURL("&format=csv&series=hourly");
+}
+
+const yesterday = new Date(Date.now() - 86400000);
+historyEnd.value = isoDate(yesterday);
+historyStart.value = isoDate(new Date(yesterday.getTime() - 6 * 86400000));
+historyEnd.max = historyEnd.value;
+historyStart.max = historyEnd.value;
+updateHistoryLinks();
+historyStart.addEventListener("change", updateHistoryLinks);
+historyEnd.addEventListener("change", updateHistoryLinks);
+
+historyBtn.addEventListener("click", async () => {
+    try {
+        const res = await fetch(historyURL(""));
+        if (!res.ok) throw new Error(await res.text());
+        const data = await res.json();
+        const u = data.units.system === "imperial" ? "°F" : "°C";
+        let html = "<table class=\"history-table\"><tr><th>Data</th><th>Condizione</th><th>Min/Max</th><th>Pioggia</th></tr>";
+        (data.daily || []).forEach(d => {
+            html += "<tr><td>" + d.label + "</td><td>" + d.condition + "</td><td>" +
+                d.min.toFixed(1) + " / " + d.max.toFixed(1) + u + "</td><td>" +
+                d.precipitation_sum.toFixed(1) + "</td></tr>";
+        });
+        historyResult.innerHTML = html + "</table>";
+    } catch (e) {
+        console.error(e);
+        showToast("Errore storico: " + e.message, "error");
+    }
+});
+
 toggleBtn.addEventListener("click", async () => {
     try {
         const res = await fetch("/meteo/toggle-notification", { method: "POST" });
@@ -412,7 +1013,8 @@ saveConfigBtn.addEventListener("click", async () => {
         const payload = {
             interval_minutes: parseInt(intervalInput.value, 10),
             start_hour: parseInt(startHourInput.value, 10),
-            end_hour: parseInt(endHourInput.value, 10)
+            end_hour: parseInt(endHourInput.value, 10),
+            units: {system: unitSystemInput.value, wind: windUnitInput.value}
         };
         const res = await fetch("/meteo/config/update", {
             method: "POST",
@@ -424,6 +1026,14 @@ saveConfigBtn.addEventListener("click", async () => {
         intervalInput.value = cfg.interval_minutes;
         startHourInput.value = cfg.start_hour;
         endHourInput.value = cfg.end_hour;
+        const unitsChanged = cfg.units.system !== {{.Units.System}} || cfg.units.wind !== {{.Units.Wind}};
+        unitSystemInput.value = cfg.units.system;
+        windUnitInput.value = cfg.units.wind;
+        if (unitsChanged) {
+            showToast("Unità aggiornate! Ricaricamento...", "success");
+            setTimeout(() => location.reload(), 1500);
+            return;
+        }
         showToast("Configurazione aggiornata con successo", "success");
     } catch (e) {
         console.error(e);
@@ -437,7 +1047,13 @@ saveConfigBtn.addEventListener("click", async () => {
 
 // homeHandler gestisce la pagina principale con l'interfaccia utente
 func homeHandler(w http.ResponseWriter, r *http.Request) {
-	data, err := getWeather()
+	v, err := newVisitor(r)
+	if err != nil {
+		writeVisitorError(w, err)
+		return
+	}
+
+	data, err := getWeather(r.Context(), v)
 	if err != nil {
 		http.Error(w, "Errore meteo: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"math"
+
+	"github.com/hectormalot/omgo"
+)
+
+// Sistemi di unità di misura
+const (
+	unitsMetric   = "metric"
+	unitsImperial = "imperial"
+)
+
+// Unità del vento
+const (
+	windKmh      = "kmh"
+	windMs       = "ms"
+	windKnots    = "kn"
+	windMph      = "mph"
+	windBeaufort = "beaufort"
+)
+
+// Units descrive le unità di misura scelte dall'utente
+type Units struct {
+	System string `json:"system"` // metric | imperial
+	Wind   string `json:"wind"`   // kmh | ms | kn | mph | beaufort
+}
+
+// currentUnits restituisce le unità configurate
+func currentUnits() Units {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return units
+}
+
+// normalize sostituisce i valori non validi con quelli di default
+func (u Units) normalize() Units {
+	if u.System != unitsImperial {
+		u.System = unitsMetric
+	}
+	switch u.Wind {
+	case windKmh, windMs, windKnots, windMph, windBeaufort:
+	default:
+		if u.System == unitsImperial {
+			u.Wind = windMph
+		} else {
+			u.Wind = windKmh
+		}
+	}
+	return u
+}
+
+// TemperatureLabel restituisce il simbolo della temperatura
+func (u Units) TemperatureLabel() string {
+	if u.System == unitsImperial {
+		return "°F"
+	}
+	return "°C"
+}
+
+// PrecipitationLabel restituisce il simbolo delle precipitazioni
+func (u Units) PrecipitationLabel() string {
+	if u.System == unitsImperial {
+		return "in"
+	}
+	return "mm"
+}
+
+// VisibilityLabel restituisce il simbolo della visibilità
+func (u Units) VisibilityLabel() string {
+	if u.System == unitsImperial {
+		return "mi"
+	}
+	return "km"
+}
+
+// WindLabel restituisce il simbolo della velocità del vento
+func (u Units) WindLabel() string {
+	switch u.Wind {
+	case windMs:
+		return "m/s"
+	case windKnots:
+		return "kn"
+	case windMph:
+		return "mph"
+	case windBeaufort:
+		return "Bft"
+	}
+	return "km/h"
+}
+
+// temperature converte da °C
+func (u Units) temperature(celsius float64) float64 {
+	if u.System == unitsImperial {
+		return celsius*9/5 + 32
+	}
+	return celsius
+}
+
+// precipitation converte da mm
+func (u Units) precipitation(mm float64) float64 {
+	if u.System == unitsImperial {
+		return mm / 25.4
+	}
+	return mm
+}
+
+// visibility converte da metri a km o miglia
+func (u Units) visibility(meters float64) float64 {
+	if u.System == unitsImperial {
+		return meters / 1609.344
+	}
+	return meters / 1000
+}
+
+// wind converte da km/h
+func (u Units) wind(kmh float64) float64 {
+	switch u.Wind {
+	case windMs:
+		return kmh / 3.6
+	case windKnots:
+		return kmh / 1.852
+	case windMph:
+		return kmh / 1.609344
+	case windBeaufort:
+		return beaufort(kmh)
+	}
+	return kmh
+}
+
+// beaufortLimits sono i limiti superiori dei gradi Beaufort 0-11 in km/h
+var beaufortLimits = [...]float64{1, 6, 12, 20, 29, 39, 50, 62, 75, 89, 103, 118}
+
+// beaufort restituisce il grado della scala Beaufort per una velocità in km/h
+func beaufort(kmh float64) float64 {
+	for force, limit := range beaufortLimits {
+		if kmh < limit {
+			return float64(force)
+		}
+	}
+	return 12
+}
+
+// celsius converte in °C, inverso di temperature
+func (u Units) celsius(value float64) float64 {
+	if u.System == unitsImperial {
+		return (value - 32) * 5 / 9
+	}
+	return value
+}
+
+// millimeters converte in mm, inverso di precipitation
+func (u Units) millimeters(value float64) float64 {
+	if u.System == unitsImperial {
+		return value * 25.4
+	}
+	return value
+}
+
+// kmh converte in km/h, inverso di wind; un grado Beaufort diventa
+// la velocità minima del grado, così da restituire lo stesso grado
+func (u Units) kmh(value float64) float64 {
+	switch u.Wind {
+	case windMs:
+		return value * 3.6
+	case windKnots:
+		return value * 1.852
+	case windMph:
+		return value * 1.609344
+	case windBeaufort:
+		force := int(math.Round(value))
+		if force <= 0 {
+			return 0
+		}
+		return beaufortLimits[min(force, len(beaufortLimits))-1]
+	}
+	return value
+}
+
+// convertMetric converte in place una previsione espressa in unità metriche
+func (u Units) convertMetric(f *Forecast) {
+	convert := func(h *HourlyPoint) {
+		h.Temperature = u.temperature(h.Temperature)
+		h.ApparentTemperature = u.temperature(h.ApparentTemperature)
+		h.Precipitation = u.precipitation(h.Precipitation)
+		h.WindSpeed = u.wind(h.WindSpeed)
+	}
+	convert(&f.Current)
+	for i := range f.Hourly {
+		convert(&f.Hourly[i])
+	}
+	for i := range f.Daily {
+		d := &f.Daily[i]
+		d.TempMax = u.temperature(d.TempMax)
+		d.TempMin = u.temperature(d.TempMin)
+		d.PrecipitationSum = u.precipitation(d.PrecipitationSum)
+		d.WindMax = u.wind(d.WindMax)
+	}
+}
+
+// convertWind converte in place solo il vento, espresso in km/h
+func (u Units) convertWind(f *Forecast) {
+	f.Current.WindSpeed = u.wind(f.Current.WindSpeed)
+	for i := range f.Hourly {
+		f.Hourly[i].WindSpeed = u.wind(f.Hourly[i].WindSpeed)
+	}
+	for i := range f.Daily {
+		f.Daily[i].WindMax = u.wind(f.Daily[i].WindMax)
+	}
+}
+
+// omgoTemperature restituisce l'unità di temperatura per Open-Meteo
+func (u Units) omgoTemperature() omgo.TemperatureUnit {
+	if u.System == unitsImperial {
+		return omgo.Fahrenheit
+	}
+	return omgo.Celsius
+}
+
+// omgoPrecipitation restituisce l'unità di precipitazione per Open-Meteo
+func (u Units) omgoPrecipitation() omgo.PrecipitationUnit {
+	if u.System == unitsImperial {
+		return omgo.Inches
+	}
+	return omgo.Millimeters
+}
+
+// omgoWind restituisce l'unità del vento per Open-Meteo,
+// che non supporta Beaufort: in quel caso si chiede km/h e si converte
+func (u Units) omgoWind() omgo.WindSpeedUnit {
+	switch u.Wind {
+	case windMs:
+		return omgo.MetersPerSecond
+	case windKnots:
+		return omgo.Knots
+	case windMph:
+		return omgo.MilesPerHour
+	}
+	return omgo.KilometersPerHour
+}
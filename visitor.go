@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// browserPositionCookie contiene la posizione inviata dal browser per una
+// visualizzazione temporanea, che non modifica le posizioni salvate
+const browserPositionCookie = "meteo_browser_position"
+
+// browserPositionTTL è la durata della visualizzazione temporanea
+const browserPositionTTL = time.Hour
+
+// Errori delle posizioni richieste nell'URL
+var (
+	errInvalidCoordinates = errors.New("coordinate non valide")
+	errPlaceNotFound      = errors.New("località non trovata")
+)
+
+// browserPosition è una posizione rilevata dalla Geolocation API del browser
+type browserPosition struct {
+	Lat      float64
+	Lon      float64
+	Accuracy float64 // raggio di accuratezza in metri
+}
+
+// visitor raccoglie quanto serve a scegliere la posizione di un visitatore
+type visitor struct {
+	ip      netip.Addr
+	browser *browserPosition
+	place   *GeoLocation // posizione richiesta nell'URL, senza modificare quelle salvate
+}
+
+// newVisitor ricava dalla richiesta l'indirizzo, l'eventuale posizione del
+// browser e quella richiesta nell'URL
+func newVisitor(r *http.Request) (visitor, error) {
+	v := visitor{ip: clientIP(r)}
+	if cookie, err := r.Cookie(browserPositionCookie); err == nil {
+		if position, err := parseBrowserPosition(cookie.Value); err == nil {
+			v.browser = &position
+		}
+	}
+
+	place, err := requestedPlace(r)
+	v.place = place
+	return v, err
+}
+
+// requestedPlace legge la posizione da ?lat=..&lon=.., ?place=nome o
+// /place/{nome}, dove nel nome i trattini valgono come spazi
+func requestedPlace(r *http.Request) (*GeoLocation, error) {
+	query := r.URL.Query()
+	if query.Has("lat") || query.Has("lon") {
+		lat, errLat := strconv.ParseFloat(query.Get("lat"), 64)
+		lon, errLon := strconv.ParseFloat(query.Get("lon"), 64)
+		if errLat != nil || errLon != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			return nil, errInvalidCoordinates
+		}
+		city, country := getCityNameFromCoordinates(r.Context(), lat, lon)
+		return &GeoLocation{Lat: lat, Lon: lon, City: city, Country: country, Source: sourceURL}, nil
+	}
+
+	name := r.PathValue("name")
+	if name == "" {
+		name = query.Get("place")
+	}
+	name = strings.TrimSpace(strings.ReplaceAll(name, "-", " "))
+	if name == "" {
+		return nil, nil
+	}
+
+	results, err := searchPlaces(r.Context(), name, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%w: %s", errPlaceNotFound, name)
+	}
+	place := results[0]
+	place.Source = sourceURL
+	return &place, nil
+}
+
+// writeVisitorError risponde con lo stato adatto a un errore di newVisitor
+func writeVisitorError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errInvalidCoordinates):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, errPlaceNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, "Errore ricerca: "+err.Error(), http.StatusBadGateway)
+	}
+}
+
+// validateBrowserPosition controlla coordinate e accuratezza
+func validateBrowserPosition(p browserPosition) error {
+	if p.Lat < -90 || p.Lat > 90 || p.Lon < -180 || p.Lon > 180 {
+		return fmt.Errorf("coordinate non valide")
+	}
+	if p.Accuracy < 0 {
+		return fmt.Errorf("accuratezza non valida")
+	}
+	return nil
+}
+
+// parseBrowserPosition legge il cookie nel formato "lat|lon|accuratezza"
+func parseBrowserPosition(value string) (browserPosition, error) {
+	parts := strings.Split(value, "|")
+	if len(parts) != 3 {
+		return browserPosition{}, fmt.Errorf("cookie posizione non valido")
+	}
+	var values [3]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return browserPosition{}, fmt.Errorf("cookie posizione non valido")
+		}
+		values[i] = v
+	}
+	p := browserPosition{Lat: values[0], Lon: values[1], Accuracy: values[2]}
+	return p, validateBrowserPosition(p)
+}
+
+// setBrowserPositionCookie salva la posizione per la visualizzazione temporanea
+func setBrowserPositionCookie(w http.ResponseWriter, p browserPosition) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     browserPositionCookie,
+		Value:    fmt.Sprintf("%.6f|%.6f|%.0f", p.Lat, p.Lon, p.Accuracy),
+		Path:     "/",
+		MaxAge:   int(browserPositionTTL / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clearBrowserPositionCookie termina la visualizzazione temporanea
+func clearBrowserPositionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     browserPositionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
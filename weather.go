@@ -2,12 +2,10 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"net/http"
+	"log"
+	"net/netip"
 	"time"
-
-	"github.com/hectormalot/omgo"
 )
 
 // getWeatherDescription restituisce la descrizione testuale del codice meteo
@@ -44,90 +42,123 @@ func getWeatherDescription(code int) string {
 	return "❓ Condizione sconosciuta"
 }
 
-// getCityNameFromCoordinates usa reverse geocoding per ottenere il nome della città
-func getCityNameFromCoordinates(lat, lon float64) (city, country string) {
-	reverseURL := fmt.Sprintf("https://nominatim.openstreetmap.org/reverse?format=json&lat=%.6f&lon=%.6f", lat, lon)
-
-	resp, err := http.Get(reverseURL)
+// getCityNameFromCoordinates usa reverse geocoding per ottenere il nome della
+// città; se il geocoder non risponde, o la coda di Nominatim è troppo lunga,
+// restituisce l'etichetta generica
+func getCityNameFromCoordinates(ctx context.Context, lat, lon float64) (city, country string) {
+	place, err := geocodeCache.get(ctx, locationKey(lat, lon), func(ctx context.Context) (GeoLocation, error) {
+		return geocoder.Reverse(ctx, lat, lon)
+	})
 	if err != nil {
+		log.Printf("⚠️ Geocoding inverso fallito per %.4f, %.4f: %v", lat, lon, err)
 		return customLocationLabel, ""
 	}
-	defer resp.Body.Close()
+	return place.City, place.Country
+}
 
-	var reverseData struct {
-		Address struct {
-			City    string `json:"city"`
-			Town    string `json:"town"`
-			Village string `json:"village"`
-			Country string `json:"country"`
-		} `json:"address"`
+// resolveLocation restituisce la posizione mostrata al visitatore seguendo
+// la catena: posizione salvata attiva, geolocalizzazione del browser, IP del
+// visitatore, posizione di default. La posizione richiesta nell'URL e la
+// visualizzazione temporanea dal browser sono scelte esplicite del visitatore
+// e prevalgono sulla posizione salvata.
+func resolveLocation(ctx context.Context, v visitor) GeoLocation {
+	if v.place != nil {
+		return *v.place
 	}
-
-	if json.NewDecoder(resp.Body).Decode(&reverseData) != nil {
-		return customLocationLabel, ""
+	if p := v.browser; p != nil {
+		city, country := getCityNameFromCoordinates(ctx, p.Lat, p.Lon)
+		return GeoLocation{
+			Lat:      p.Lat,
+			Lon:      p.Lon,
+			City:     city,
+			Country:  country,
+			Accuracy: p.Accuracy,
+			Source:   sourceBrowser,
+		}
 	}
 
-	city = reverseData.Address.City
-	if city == "" {
-		city = reverseData.Address.Town
-	}
-	if city == "" {
-		city = reverseData.Address.Village
-	}
-	if city == "" {
-		city = customLocationLabel
+	locationMutex.RLock()
+	id := activeLocationID
+	locationMutex.RUnlock()
+
+	return resolveSavedLocation(ctx, id, v.ip)
+}
+
+// resolveNotificationLocation restituisce la posizione usata dalle notifiche;
+// senza un visitatore la modalità automatica usa la posizione del server
+func resolveNotificationLocation() GeoLocation {
+	locationMutex.RLock()
+	id := notificationLocationID
+	if id == "" {
+		id = activeLocationID
 	}
+	locationMutex.RUnlock()
 
-	return city, reverseData.Address.Country
+	return resolveSavedLocation(context.Background(), id, netip.Addr{})
 }
 
-// getWeather recupera i dati meteo per la posizione attuale o personalizzata
-func getWeather() (*WeatherData, error) {
-	var location GeoLocation
+// resolveDefaultLocation restituisce la posizione di default configurata
+func resolveDefaultLocation(ctx context.Context) GeoLocation {
+	configMutex.RLock()
+	location := defaultLocation
+	configMutex.RUnlock()
 
-	// Usa coordinate personalizzate se impostate
-	locationMutex.RLock()
-	if useCustom {
-		location.Lat = customLat
-		location.Lon = customLon
-		locationMutex.RUnlock()
-		location.City, location.Country = getCityNameFromCoordinates(location.Lat, location.Lon)
-	} else {
-		locationMutex.RUnlock()
-		// Geolocalizzazione automatica
-		resp, err := http.Get("http://ip-api.com/json/")
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
+	if location.City == "" {
+		location.City, location.Country = getCityNameFromCoordinates(ctx, location.Lat, location.Lon)
+	}
+	location.Source = sourceDefault
+	return location
+}
 
-		if err := json.NewDecoder(resp.Body).Decode(&location); err != nil {
-			return nil, err
-		}
+// getWeather recupera i dati meteo per la posizione mostrata al visitatore
+func getWeather(ctx context.Context, v visitor) (*WeatherData, error) {
+	return getWeatherAt(resolveLocation(ctx, v))
+}
+
+// getNotificationWeather recupera i dati meteo per la posizione delle notifiche
+func getNotificationWeather() (*WeatherData, error) {
+	return getWeatherAt(resolveNotificationLocation())
+}
+
+// LocationSourceLabel descrive la sorgente della posizione mostrata
+func (d *WeatherData) LocationSourceLabel() string {
+	switch d.LocationSource {
+	case sourceURL:
+		return "🔗 posizione dal link"
+	case sourceBrowser:
+		return "📱 posizione del browser"
+	case sourceSaved:
+		return "⭐ posizione salvata"
+	case sourceIP:
+		return "📡 posizione dall'IP"
+	case sourceDefault:
+		return "🏠 posizione di default"
 	}
+	return ""
+}
 
-	client := omgo.NewClient()
-	req, err := omgo.NewForecastRequest(location.Lat, location.Lon)
+// getWeatherAt recupera i dati meteo per la posizione indicata
+func getWeatherAt(location GeoLocation) (*WeatherData, error) {
+	configMutex.RLock()
+	days := forecastDays
+	u := units
+	configMutex.RUnlock()
+
+	forecast, err := weatherProvider.Forecast(context.Background(), ForecastQuery{
+		Lat:      location.Lat,
+		Lon:      location.Lon,
+		Days:     days,
+		Timezone: location.Timezone,
+		Units:    u,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	req.WithHourly(
-		omgo.HourlyTemperature2m,
-		omgo.HourlyWeatherCode,
-		omgo.HourlyPrecipitation,
-		omgo.HourlyWindSpeed10m,
-		omgo.HourlyRelativeHumidity2m,
-		omgo.HourlyVisibility,
-	).WithDaily(
-		omgo.DailyTemperature2mMax,
-		omgo.DailyTemperature2mMin,
-		omgo.DailyWeatherCode,
-	).WithTimezone("Europe/Rome")
-
-	weather, err := client.Forecast(context.Background(), req)
+	// La qualità dell'aria è facoltativa: un errore non blocca il meteo
+	airQuality, err := getAirQuality(location.Lat, location.Lon)
 	if err != nil {
-		return nil, err
+		log.Printf("⚠️ Qualità dell'aria non disponibile: %v", err)
 	}
 
 	notificationsMutex.RLock()
@@ -140,24 +171,40 @@ func getWeather() (*WeatherData, error) {
 	end := notificationEndHour
 	configMutex.RUnlock()
 
+	current := forecast.Current
+	today := forecast.dailyAt(0)
+	now := time.Now().In(loadTimezone(forecast.Timezone))
+
 	data := &WeatherData{
 		City:                 location.City,
 		Country:              location.Country,
 		Lat:                  location.Lat,
 		Lon:                  location.Lon,
-		Time:                 time.Now().Format("15:04 - 02/01/2006"),
-		CurrentCondition:     getWeatherDescription(int(weather.Hourly.WeatherCode[0])),
-		CurrentTemp:          weather.Hourly.Temperature2m[0],
-		Humidity:             weather.Hourly.RelativeHumidity2m[0],
-		WindSpeed:            weather.Hourly.WindSpeed10m[0],
-		Visibility:           weather.Hourly.Visibility[0] / 1000,
-		Precipitation:        weather.Hourly.Precipitation[0],
-		TodayMax:             weather.Daily.Temperature2mMax[0],
-		TodayMin:             weather.Daily.Temperature2mMin[0],
-		TodayCondition:       getWeatherDescription(int(weather.Daily.WeatherCode[0])),
-		TomorrowMax:          weather.Daily.Temperature2mMax[1],
-		TomorrowMin:          weather.Daily.Temperature2mMin[1],
-		TomorrowCondition:    getWeatherDescription(int(weather.Daily.WeatherCode[1])),
+		Accuracy:             location.Accuracy,
+		LocationSource:       location.Source,
+		Time:                 now.Format("15:04 - 02/01/2006"),
+		Timezone:             forecast.Timezone,
+		ObservedAt:           current.Time,
+		WeatherCode:          current.WeatherCode,
+		CurrentCondition:     getWeatherDescription(current.WeatherCode),
+		CurrentTemp:          current.Temperature,
+		FeelsLike:            current.ApparentTemperature,
+		Humidity:             current.Humidity,
+		WindSpeed:            current.WindSpeed,
+		Visibility:           u.visibility(current.Visibility),
+		HasVisibility:        forecast.HasVisibility,
+		Precipitation:        current.Precipitation,
+		UVIndex:              today.UVIndexMax,
+		Sunrise:              today.Sunrise,
+		Sunset:               today.Sunset,
+		Daylight:             formatDaylight(today.Daylight),
+		Days:                 buildDays(forecast.Daily),
+		Hours:                buildHours(forecast.Hourly, now),
+		Units:                u,
+		AirQuality:           airQuality,
+		Provider:             forecast.Provider,
+		Locations:            listLocations(),
+		AlertRules:           alertRulePayloads(u),
 		NotificationsEnabled: enabled,
 		IntervalMinutes:      interval,
 		StartHour:            start,
@@ -167,3 +214,79 @@ func getWeather() (*WeatherData, error) {
 
 	return data, nil
 }
+
+// buildDays converte i giorni del provider nel formato del template
+func buildDays(daily []DailyPoint) []DayForecast {
+	days := make([]DayForecast, 0, len(daily))
+	for i, d := range daily {
+		days = append(days, toDayForecast(d, dayLabel(i, d.Date)))
+	}
+	return days
+}
+
+// toDayForecast converte un giorno del provider con l'etichetta indicata
+func toDayForecast(d DailyPoint, label string) DayForecast {
+	return DayForecast{
+		Date:                     d.Date,
+		Label:                    label,
+		Min:                      d.TempMin,
+		Max:                      d.TempMax,
+		WeatherCode:              d.WeatherCode,
+		Condition:                getWeatherDescription(d.WeatherCode),
+		PrecipitationSum:         d.PrecipitationSum,
+		PrecipitationProbability: d.PrecipitationProbability,
+		WindMax:                  d.WindMax,
+		UVIndexMax:               d.UVIndexMax,
+		Sunrise:                  d.Sunrise,
+		Sunset:                   d.Sunset,
+		Daylight:                 formatDaylight(d.Daylight),
+	}
+}
+
+// formatDaylight formatta la durata del giorno come "10h 32m"
+func formatDaylight(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%dh %02dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// dayLabel restituisce "Oggi", "Domani" o il giorno della settimana con la data
+func dayLabel(i int, date time.Time) string {
+	switch i {
+	case 0:
+		return "Oggi"
+	case 1:
+		return "Domani"
+	}
+	weekdays := [...]string{"Dom", "Lun", "Mar", "Mer", "Gio", "Ven", "Sab"}
+	return fmt.Sprintf("%s %02d/%02d", weekdays[date.Weekday()], date.Day(), int(date.Month()))
+}
+
+// buildHours restituisce le prossime ore a partire da quella corrente
+func buildHours(hourly []HourlyPoint, now time.Time) []HourForecast {
+	start := currentHourIndex(hourly, now)
+	end := min(start+hourlyTimelineHours, len(hourly))
+
+	hours := make([]HourForecast, 0, end-start)
+	for _, h := range hourly[start:end] {
+		hours = append(hours, toHourForecast(h))
+	}
+	return hours
+}
+
+// toHourForecast converte un'ora del provider nel formato del template
+func toHourForecast(h HourlyPoint) HourForecast {
+	return HourForecast{
+		Time:                     h.Time,
+		Temperature:              h.Temperature,
+		ApparentTemperature:      h.ApparentTemperature,
+		WeatherCode:              h.WeatherCode,
+		Condition:                getWeatherDescription(h.WeatherCode),
+		Precipitation:            h.Precipitation,
+		PrecipitationProbability: h.PrecipitationProbability,
+		WindSpeed:                h.WindSpeed,
+		Humidity:                 h.Humidity,
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Valori di default dei webhook generici
+const (
+	webhookSignatureHeader = "X-Meteo-Signature"
+	webhookMaxAttempts     = 3
+	webhookRetryDelay      = 2 * time.Second
+	webhookAttemptTimeout  = 10 * time.Second // durata massima di un singolo tentativo
+	webhookMaxBudget       = 10 * time.Minute // tentativi più attese, nel caso peggiore
+)
+
+// webhookDefaultTemplate è il corpo inviato se il webhook non ne definisce uno
+const webhookDefaultTemplate = `{
+  "event": {{json .Event}},
+  "city": {{json .City}},
+  "country": {{json .Country}},
+  "lat": {{.Lat}},
+  "lon": {{.Lon}},
+  "observed_at": {{json .ObservedAt}},
+  "weather_code": {{.WeatherCode}},
+  "condition": {{json .CurrentCondition}},
+  "temperature": {{.CurrentTemp}},
+  "feels_like": {{.FeelsLike}},
+  "humidity": {{.Humidity}},
+  "wind_speed": {{.WindSpeed}},
+  "precipitation": {{.Precipitation}},
+  "units": {{json .Units}},
+  "summary": {{json .Summary}},
+  "alerts": {{json .Alerts}},
+  "url": {{json .Link}}
+}`
+
+// webhookConfig è la definizione di un webhook nel file WEBHOOKS_FILE
+type webhookConfig struct {
+	Name            string            `json:"name"`
+	URL             string            `json:"url"`
+	Method          string            `json:"method"`       // default POST
+	ContentType     string            `json:"content_type"` // default application/json
+	Template        string            `json:"template"`
+	TemplateFile    string            `json:"template_file"` // relativo al file dei webhook
+	Headers         map[string]string `json:"headers"`
+	Secret          string            `json:"secret"`           // chiave HMAC-SHA256 del corpo
+	SignatureHeader string            `json:"signature_header"` // default X-Meteo-Signature
+	MaxAttempts     int               `json:"max_attempts"`     // tentativi totali, default 3
+	RetryDelay      string            `json:"retry_delay"`      // attesa iniziale, raddoppia a ogni tentativo
+}
+
+// webhookData è il dato passato al template: il meteo più l'evento, il
+// riepilogo testuale, gli avvisi scattati, il link alla pagina e l'ora di invio
+type webhookData struct {
+	*WeatherData
+	Event   string
+	Summary string
+	Alerts  []AlertEvent
+	Link    string
+	SentAt  time.Time
+}
+
+// webhookFuncs sono le funzioni disponibili nei template dei webhook
+var webhookFuncs = template.FuncMap{
+	// json codifica un valore come JSON, anche per inserire stringhe in sicurezza
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"rfc3339": func(t time.Time) string {
+		return t.Format(time.RFC3339)
+	},
+}
+
+// webhookNotifier invia il meteo a un endpoint HTTP con un corpo definito da
+// un template, firmato con HMAC-SHA256 e ritentato sugli errori temporanei
+type webhookNotifier struct {
+	name            string
+	url             string
+	method          string
+	contentType     string
+	body            *template.Template
+	headers         map[string]string
+	secret          string
+	signatureHeader string
+	maxAttempts     int
+	retryDelay      time.Duration
+}
+
+// errWebhookPermanent indica una risposta che non ha senso ritentare
+var errWebhookPermanent = errors.New("errore non recuperabile")
+
+// loadWebhookNotifiers legge il file dei webhook e crea un canale per ciascuno
+func loadWebhookNotifiers(path string) ([]Notifier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var configs []webhookConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var list []Notifier
+	for i, c := range configs {
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("%d", i+1)
+		}
+		if c.TemplateFile != "" && !filepath.IsAbs(c.TemplateFile) {
+			c.TemplateFile = filepath.Join(filepath.Dir(path), c.TemplateFile)
+		}
+		w, err := newWebhookNotifier(c)
+		if err != nil {
+			return nil, fmt.Errorf("webhook %s: %w", c.Name, err)
+		}
+		list = append(list, w)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("%s: nessun webhook definito", path)
+	}
+	return list, nil
+}
+
+// newWebhookNotifier valida la configurazione e compila il template
+func newWebhookNotifier(c webhookConfig) (*webhookNotifier, error) {
+	if !strings.HasPrefix(c.URL, "http://") && !strings.HasPrefix(c.URL, "https://") {
+		return nil, fmt.Errorf("url non valido %q", c.URL)
+	}
+
+	w := &webhookNotifier{
+		name:            c.Name,
+		url:             c.URL,
+		method:          strings.ToUpper(c.Method),
+		contentType:     c.ContentType,
+		headers:         c.Headers,
+		secret:          c.Secret,
+		signatureHeader: c.SignatureHeader,
+		maxAttempts:     c.MaxAttempts,
+		retryDelay:      webhookRetryDelay,
+	}
+	if w.method == "" {
+		w.method = http.MethodPost
+	}
+	if w.contentType == "" {
+		w.contentType = contentTypeJSON
+	}
+	if w.signatureHeader == "" {
+		w.signatureHeader = webhookSignatureHeader
+	}
+	if w.maxAttempts <= 0 {
+		w.maxAttempts = webhookMaxAttempts
+	}
+	if c.RetryDelay != "" {
+		delay, err := time.ParseDuration(c.RetryDelay)
+		if err != nil || delay < 0 {
+			return nil, fmt.Errorf("retry_delay non valido %q", c.RetryDelay)
+		}
+		w.retryDelay = delay
+	}
+	if budget := w.timeout(); budget > webhookMaxBudget {
+		return nil, fmt.Errorf("max_attempts e retry_delay richiedono fino a %v, oltre il limite di %v", budget, webhookMaxBudget)
+	}
+
+	text := c.Template
+	if c.TemplateFile != "" {
+		data, err := os.ReadFile(c.TemplateFile)
+		if err != nil {
+			return nil, err
+		}
+		text = string(data)
+	}
+	if text == "" {
+		text = webhookDefaultTemplate
+	}
+	body, err := template.New(c.Name).Funcs(webhookFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	w.body = body
+	return w, nil
+}
+
+// Name restituisce il nome del canale
+func (w *webhookNotifier) Name() string {
+	return "webhook:" + w.name
+}
+
+// timeout restituisce il tempo necessario nel caso peggiore: ogni tentativo
+// fino a webhookAttemptTimeout più le attese tra un tentativo e l'altro
+func (w *webhookNotifier) timeout() time.Duration {
+	total := webhookAttemptTimeout
+	delay := w.retryDelay
+	for attempt := 1; attempt < w.maxAttempts; attempt++ {
+		// Oltre il limite ci si ferma, evitando l'overflow dei raddoppi
+		if total > webhookMaxBudget {
+			break
+		}
+		total += delay + webhookAttemptTimeout
+		delay *= 2
+	}
+	return total
+}
+
+// Notify rende il template e lo invia, ritentando con attesa crescente
+func (w *webhookNotifier) Notify(ctx context.Context, n Notification) error {
+	var body bytes.Buffer
+	err := w.body.Execute(&body, webhookData{
+		WeatherData: n.Data,
+		Event:       n.Event,
+		Summary:     n.Summary.Text(),
+		Alerts:      n.Alerts,
+		Link:        homePageURL(n.Data),
+		SentAt:      time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("template: %w", err)
+	}
+	if strings.HasPrefix(w.contentType, contentTypeJSON) && !json.Valid(body.Bytes()) {
+		return fmt.Errorf("il template non produce JSON valido")
+	}
+
+	delay := w.retryDelay
+	for attempt := 1; ; attempt++ {
+		err = w.send(ctx, body.Bytes())
+		if err == nil || errors.Is(err, errWebhookPermanent) || attempt >= w.maxAttempts {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w (ultimo errore: %v)", ctx.Err(), err)
+		}
+		delay *= 2
+	}
+}
+
+// send esegue un tentativo; gli stati 4xx, tranne 408 e 429, sono definitivi
+func (w *webhookNotifier) send(ctx context.Context, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, webhookAttemptTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, w.method, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(contentTypeHeader, w.contentType)
+	req.Header.Set("User-Agent", "go-meteo/"+AppVersion)
+	for name, value := range w.headers {
+		req.Header.Set(name, value)
+	}
+	if w.secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.secret))
+		mac.Write(body)
+		req.Header.Set(w.signatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return fmt.Errorf("status %d", resp.StatusCode)
+	default:
+		return fmt.Errorf("%w: status %d", errWebhookPermanent, resp.StatusCode)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// webhookRequest è una richiesta ricevuta dallo stand-in dei webhook
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// newWebhookServer avvia uno stand-in che risponde con gli stati indicati,
+// uno per richiesta, ripetendo l'ultimo
+func newWebhookServer(t *testing.T, statuses ...int) (*httptest.Server, *[]webhookRequest) {
+	t.Helper()
+	var (
+		requests []webhookRequest
+		count    atomic.Int32
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, webhookRequest{header: r.Header.Clone(), body: body})
+		i := min(int(count.Add(1))-1, len(statuses)-1)
+		w.WriteHeader(statuses[i])
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestWebhookSignature(t *testing.T) {
+	srv, requests := newWebhookServer(t, http.StatusNoContent)
+	w, err := newWebhookNotifier(webhookConfig{
+		Name:    "firmato",
+		URL:     srv.URL,
+		Secret:  "chiave-condivisa",
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Notify(context.Background(), testNotification()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	req := (*requests)[0]
+	mac := hmac.New(sha256.New, []byte("chiave-condivisa"))
+	mac.Write(req.body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := req.header.Get(webhookSignatureHeader); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+	if req.header.Get("Authorization") != "Bearer token" || req.header.Get(contentTypeHeader) != contentTypeJSON {
+		t.Errorf("header = %v", req.header)
+	}
+
+	// Senza segreto non si firma; il nome dell'header è configurabile
+	w.secret = ""
+	if err := w.Notify(context.Background(), testNotification()); err != nil {
+		t.Fatal(err)
+	}
+	if got := (*requests)[1].header.Get(webhookSignatureHeader); got != "" {
+		t.Errorf("unsigned request has signature %q", got)
+	}
+}
+
+func TestWebhookDefaultTemplate(t *testing.T) {
+	previous := publicURL
+	publicURL = "https://meteo.example.com"
+	t.Cleanup(func() { publicURL = previous })
+
+	srv, requests := newWebhookServer(t, http.StatusOK)
+	w, err := newWebhookNotifier(webhookConfig{Name: "default", URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := testNotification()
+	n.Event = eventAlert
+	n.Alerts = []AlertEvent{{RuleID: "r1", Message: "Vento \"forte\""}}
+	if err := w.Notify(context.Background(), n); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal((*requests)[0].body, &body); err != nil {
+		t.Fatalf("body non JSON: %v\n%s", err, (*requests)[0].body)
+	}
+	if body["event"] != eventAlert || body["city"] != "Roma" || body["temperature"] != 24.5 || body["weather_code"] != 61.0 {
+		t.Errorf("body = %v", body)
+	}
+	if body["url"] != "https://meteo.example.com/?lat=41.9028&lon=12.4964" || body["observed_at"] != "2026-07-01T10:15:00Z" {
+		t.Errorf("url/observed_at = %v / %v", body["url"], body["observed_at"])
+	}
+	if s, _ := body["summary"].(string); !strings.HasPrefix(s, "🌤️ Meteo Roma\n") {
+		t.Errorf("summary = %q", body["summary"])
+	}
+	if alerts, _ := body["alerts"].([]any); len(alerts) != 1 {
+		t.Errorf("alerts = %v", body["alerts"])
+	}
+}
+
+func TestWebhookCustomTemplate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "corpo.tmpl"), []byte(`luogo={{.City}} t={{printf "%.1f" .CurrentTemp}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	srv, requests := newWebhookServer(t, http.StatusOK)
+	config := `[
+		{"name": "testo", "url": "` + srv.URL + `", "method": "put", "content_type": "text/plain", "template_file": "corpo.tmpl"},
+		{"url": "` + srv.URL + `", "template": "{\"luogo\": {{json .City}}, \"inviato\": {{json (rfc3339 .SentAt)}}}"}
+	]`
+	path := filepath.Join(dir, "webhooks.json")
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := loadWebhookNotifiers(path)
+	if err != nil {
+		t.Fatalf("loadWebhookNotifiers: %v", err)
+	}
+	if got := notifierNames(list); got != "webhook:testo,webhook:2" {
+		t.Errorf("names = %s", got)
+	}
+	for _, n := range list {
+		if err := n.Notify(context.Background(), testNotification()); err != nil {
+			t.Fatalf("%s: %v", n.Name(), err)
+		}
+	}
+
+	// Il template su file è relativo al file dei webhook
+	if got := string((*requests)[0].body); got != "luogo=Roma t=24.5" {
+		t.Errorf("text body = %q", got)
+	}
+	if got := (*requests)[0].header.Get(contentTypeHeader); got != "text/plain" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	var body struct{ Luogo, Inviato string }
+	if err := json.Unmarshal((*requests)[1].body, &body); err != nil || body.Luogo != "Roma" {
+		t.Errorf("json body = %s (%v)", (*requests)[1].body, err)
+	}
+	if _, err := time.Parse(time.RFC3339, body.Inviato); err != nil {
+		t.Errorf("inviato = %q", body.Inviato)
+	}
+}
+
+func TestWebhookInvalidJSON(t *testing.T) {
+	srv, requests := newWebhookServer(t, http.StatusOK)
+	w, err := newWebhookNotifier(webhookConfig{URL: srv.URL, Template: `{"luogo": {{.City}}}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Notify(context.Background(), testNotification())
+	if err == nil || !strings.Contains(err.Error(), "JSON valido") {
+		t.Errorf("Notify = %v, want invalid JSON error", err)
+	}
+	if len(*requests) != 0 {
+		t.Errorf("invalid body was sent %d times", len(*requests))
+	}
+
+	// Con un tipo diverso da JSON il corpo non viene validato
+	w.contentType = "text/plain"
+	if err := w.Notify(context.Background(), testNotification()); err != nil || len(*requests) != 1 {
+		t.Errorf("text body: err = %v, requests = %d", err, len(*requests))
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		statuses []int
+		attempts int
+		wantErr  bool
+	}{
+		{"ok al primo tentativo", []int{200}, 1, false},
+		{"5xx poi ok", []int{500, 503, 200}, 3, false},
+		{"429 poi ok", []int{429, 200}, 2, false},
+		{"408 poi ok", []int{408, 202}, 2, false},
+		{"5xx fino all'ultimo tentativo", []int{502}, 3, true},
+		{"4xx definitivo", []int{400, 200}, 1, true},
+		{"404 definitivo", []int{404, 200}, 1, true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			srv, requests := newWebhookServer(t, c.statuses...)
+			w, err := newWebhookNotifier(webhookConfig{URL: srv.URL, MaxAttempts: 3, RetryDelay: "1ms"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = w.Notify(context.Background(), testNotification())
+			if (err != nil) != c.wantErr {
+				t.Errorf("Notify = %v, wantErr %v", err, c.wantErr)
+			}
+			if len(*requests) != c.attempts {
+				t.Errorf("attempts = %d, want %d", len(*requests), c.attempts)
+			}
+			if c.attempts == 1 && c.wantErr && !errors.Is(err, errWebhookPermanent) {
+				t.Errorf("err = %v, want errWebhookPermanent", err)
+			}
+		})
+	}
+}
+
+func TestWebhookRetryStopsOnContext(t *testing.T) {
+	srv, requests := newWebhookServer(t, http.StatusServiceUnavailable)
+	w, err := newWebhookNotifier(webhookConfig{URL: srv.URL, MaxAttempts: 2, RetryDelay: "5m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = w.Notify(ctx, testNotification())
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "status 503") {
+		t.Errorf("Notify = %v, want deadline with last error", err)
+	}
+	if len(*requests) != 1 {
+		t.Errorf("attempts = %d, want 1", len(*requests))
+	}
+}
+
+func TestWebhookTimeoutBudget(t *testing.T) {
+	w, err := newWebhookNotifier(webhookConfig{URL: "https://example.com", MaxAttempts: 4, RetryDelay: "5s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 4 tentativi più le attese di 5, 10 e 20 secondi
+	want := 4*webhookAttemptTimeout + 35*time.Second
+	if got := w.timeout(); got != want {
+		t.Errorf("timeout = %v, want %v", got, want)
+	}
+
+	// Il contesto di notifyAll copre l'intero budget del webhook
+	if got := channelTimeout(w); got != want {
+		t.Errorf("channelTimeout = %v, want %v", got, want)
+	}
+	if got := channelTimeout(&fakeNotifier{}); got != notifyTimeout {
+		t.Errorf("channelTimeout(fake) = %v, want %v", got, notifyTimeout)
+	}
+	short, _ := newWebhookNotifier(webhookConfig{URL: "https://example.com", MaxAttempts: 1})
+	if got := channelTimeout(short); got != notifyTimeout {
+		t.Errorf("channelTimeout(short) = %v, want %v", got, notifyTimeout)
+	}
+}
+
+func TestNewWebhookNotifierValidation(t *testing.T) {
+	for _, c := range []webhookConfig{
+		{URL: "ftp://example.com"},
+		{URL: "https://example.com", RetryDelay: "presto"},
+		{URL: "https://example.com", RetryDelay: "-1s"},
+		{URL: "https://example.com", Template: "{{.City"},
+		{URL: "https://example.com", TemplateFile: "/non/esiste.tmpl"},
+		// Attese che non stanno nel limite complessivo
+		{URL: "https://example.com", MaxAttempts: 3, RetryDelay: "1h"},
+		{URL: "https://example.com", MaxAttempts: 1000, RetryDelay: "1s"},
+		{URL: "https://example.com", MaxAttempts: 100, RetryDelay: "2562047h"},
+	} {
+		if _, err := newWebhookNotifier(c); err == nil {
+			t.Errorf("newWebhookNotifier(%+v): expected error", c)
+		}
+	}
+
+	w, err := newWebhookNotifier(webhookConfig{URL: "http://example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.method != http.MethodPost || w.maxAttempts != webhookMaxAttempts || w.retryDelay != webhookRetryDelay || w.signatureHeader != webhookSignatureHeader {
+		t.Errorf("defaults = %+v", w)
+	}
+}